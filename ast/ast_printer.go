@@ -3,6 +3,7 @@ package ast
 import (
 	"fmt"
 	"lox/util"
+	"strings"
 )
 
 func AstPrinter(expr Expr) string {
@@ -18,7 +19,29 @@ func AstPrinter(expr Expr) string {
 	case *ConditionNode:
 		return conditionPrinter(expr)
 	case *VariableNode:
-		return fmt.Sprintf("var %s;", expr.Name.Lexeme)
+		return expr.Name.Lexeme
+	case *AssignNode:
+		return fmt.Sprintf("(= %s %s)", expr.Name.Lexeme, AstPrinter(expr.Value))
+	case *CompoundAssignNode:
+		return fmt.Sprintf("(%s %s %s)", expr.Op.Lexeme, AstPrinter(expr.Target), AstPrinter(expr.Value))
+	case *UpdateNode:
+		return fmt.Sprintf("(%s %s)", util.When(expr.Prefix, expr.Op.Lexeme+"pre", expr.Op.Lexeme+"post"), AstPrinter(expr.Target))
+	case *SetNode:
+		return fmt.Sprintf("(.= %s %s %s)", AstPrinter(expr.Object), expr.Name.Lexeme, AstPrinter(expr.Value))
+	case *SetIndexNode:
+		return fmt.Sprintf("([]= %s %s %s)", AstPrinter(expr.Object), AstPrinter(expr.Index), AstPrinter(expr.Value))
+	case *CallNode:
+		return callPrinter(expr)
+	case *ListNode:
+		return listPrinter(expr)
+	case *MapNode:
+		return mapPrinter(expr)
+	case *IndexNode:
+		return fmt.Sprintf("([] %s %s)", AstPrinter(expr.Object), AstPrinter(expr.Index))
+	case *GetNode:
+		return fmt.Sprintf("(. %s %s)", AstPrinter(expr.Object), expr.Name.Lexeme)
+	case *LambdaNode:
+		return lambdaPrinter(expr)
 	default:
 		return fmt.Sprintf("not a valid node, %+#v", expr)
 	}
@@ -29,6 +52,94 @@ func conditionPrinter(expr *ConditionNode) string {
 	falseVal := AstPrinter(expr.False)
 	return fmt.Sprintf("(%s ? %s : %s)", condition, trueVal, falseVal)
 }
+func callPrinter(expr *CallNode) string {
+	var sb strings.Builder
+	sb.WriteString("(call ")
+	sb.WriteString(AstPrinter(expr.Callee))
+	for _, arg := range expr.Args {
+		sb.WriteString(" ")
+		sb.WriteString(AstPrinter(arg))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+func listPrinter(expr *ListNode) string {
+	elements := make([]string, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		elements = append(elements, AstPrinter(element))
+	}
+	return fmt.Sprintf("(list %s)", strings.Join(elements, " "))
+}
+func mapPrinter(expr *MapNode) string {
+	entries := make([]string, 0, len(expr.Keys))
+	for idx := range expr.Keys {
+		entries = append(entries, fmt.Sprintf("(%s %s)", AstPrinter(expr.Keys[idx]), AstPrinter(expr.Values[idx])))
+	}
+	return fmt.Sprintf("(map %s)", strings.Join(entries, " "))
+}
+func lambdaPrinter(expr *LambdaNode) string {
+	params := make([]string, 0, len(expr.Params))
+	for _, param := range expr.Params {
+		params = append(params, param.Lexeme)
+	}
+	return fmt.Sprintf("(fun (%s)%s)", strings.Join(params, " "), bodyPrinter(expr.Body))
+}
+
+// StmtPrinter prints a statement in the same parenthesized form as
+// AstPrinter, nesting the statements of blocks and bodies.
+func StmtPrinter(stmt Stmt) string {
+	switch stmt := stmt.(type) {
+	case *PrintStmt:
+		return fmt.Sprintf("(print %s)", AstPrinter(stmt.Value))
+	case *ExpressionStmt:
+		return fmt.Sprintf("(; %s)", AstPrinter(stmt.Expression))
+	case *VariableStmt:
+		kind := strings.ToLower(stmt.Kind.String())
+		if stmt.Value == nil {
+			return fmt.Sprintf("(%s %s)", kind, stmt.Name.Lexeme)
+		}
+		return fmt.Sprintf("(%s %s %s)", kind, stmt.Name.Lexeme, AstPrinter(stmt.Value))
+	case *BlockStmt:
+		return fmt.Sprintf("(block%s)", bodyPrinter(stmt.Stmts))
+	case *IfStmt:
+		if stmt.Else == nil {
+			return fmt.Sprintf("(if %s %s)", AstPrinter(stmt.Cond), StmtPrinter(stmt.Then))
+		}
+		return fmt.Sprintf("(if %s %s %s)", AstPrinter(stmt.Cond), StmtPrinter(stmt.Then), StmtPrinter(stmt.Else))
+	case *FunctionStmt:
+		params := make([]string, 0, len(stmt.Params))
+		for _, param := range stmt.Params {
+			params = append(params, param.Lexeme)
+		}
+		return fmt.Sprintf("(fun %s (%s)%s)", stmt.Name.Lexeme, strings.Join(params, " "), bodyPrinter(stmt.Body))
+	case *ReturnStmt:
+		if stmt.Value == nil {
+			return "(return)"
+		}
+		return fmt.Sprintf("(return %s)", AstPrinter(stmt.Value))
+	case *ImportStmt:
+		return fmt.Sprintf("(import %s %s)", stmt.Path.Lexeme, stmt.Name.Lexeme)
+	case *ExportStmt:
+		return fmt.Sprintf("(export %s)", StmtPrinter(stmt.Decl))
+	case *AssertStmt:
+		if stmt.Message == nil {
+			return fmt.Sprintf("(assert %s)", AstPrinter(stmt.Condition))
+		}
+		return fmt.Sprintf("(assert %s %s)", AstPrinter(stmt.Condition), AstPrinter(stmt.Message))
+	case *TestStmt:
+		return fmt.Sprintf("(test %s%s)", stmt.Name.Lexeme, bodyPrinter(stmt.Body))
+	default:
+		return fmt.Sprintf("not a valid statement, %+#v", stmt)
+	}
+}
+func bodyPrinter(stmts []Stmt) string {
+	var sb strings.Builder
+	for _, stmt := range stmts {
+		sb.WriteString(" ")
+		sb.WriteString(StmtPrinter(stmt))
+	}
+	return sb.String()
+}
 func bianryPrinter(expr *BinaryNode) string {
 	left := AstPrinter(expr.Left)
 	op := expr.Op.Lexeme
@@ -45,5 +156,5 @@ func groupingPrinter(expr *GroupNode) string {
 	return fmt.Sprintf("(%s)", str)
 }
 func literalPrinter(expr *LiteralNode) string {
-	return util.When(expr.Value == nil, "nil", fmt.Sprint(expr.Value))
+	return literalSource(expr.Value)
 }
@@ -0,0 +1,87 @@
+package ast
+
+// Inspect walks a statement or expression depth first: it calls fn with
+// node and, if fn returns true, inspects each child of node in source
+// order. Function bodies are children of their declarations and missing
+// children, such as an absent else branch, are skipped.
+func Inspect(node any, fn func(node any) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *PrintStmt:
+		Inspect(node.Value, fn)
+	case *ExpressionStmt:
+		Inspect(node.Expression, fn)
+	case *VariableStmt:
+		Inspect(node.Value, fn)
+	case *BlockStmt:
+		inspectStmts(node.Stmts, fn)
+	case *IfStmt:
+		Inspect(node.Cond, fn)
+		Inspect(node.Then, fn)
+		Inspect(node.Else, fn)
+	case *FunctionStmt:
+		inspectStmts(node.Body, fn)
+	case *ReturnStmt:
+		Inspect(node.Value, fn)
+	case *ExportStmt:
+		Inspect(node.Decl, fn)
+	case *AssertStmt:
+		Inspect(node.Condition, fn)
+		Inspect(node.Message, fn)
+	case *TestStmt:
+		inspectStmts(node.Body, fn)
+	case *AssignNode:
+		Inspect(node.Value, fn)
+	case *CompoundAssignNode:
+		Inspect(node.Target, fn)
+		Inspect(node.Value, fn)
+	case *UpdateNode:
+		Inspect(node.Target, fn)
+	case *BinaryNode:
+		Inspect(node.Left, fn)
+		Inspect(node.Right, fn)
+	case *UnaryNode:
+		Inspect(node.Right, fn)
+	case *GroupNode:
+		Inspect(node.Expression, fn)
+	case *ConditionNode:
+		Inspect(node.Condition, fn)
+		Inspect(node.Truth, fn)
+		Inspect(node.False, fn)
+	case *CallNode:
+		Inspect(node.Callee, fn)
+		for _, arg := range node.Args {
+			Inspect(arg, fn)
+		}
+	case *LambdaNode:
+		inspectStmts(node.Body, fn)
+	case *GetNode:
+		Inspect(node.Object, fn)
+	case *SetNode:
+		Inspect(node.Object, fn)
+		Inspect(node.Value, fn)
+	case *ListNode:
+		for _, element := range node.Elements {
+			Inspect(element, fn)
+		}
+	case *IndexNode:
+		Inspect(node.Object, fn)
+		Inspect(node.Index, fn)
+	case *SetIndexNode:
+		Inspect(node.Object, fn)
+		Inspect(node.Index, fn)
+		Inspect(node.Value, fn)
+	case *MapNode:
+		for idx := range node.Keys {
+			Inspect(node.Keys[idx], fn)
+			Inspect(node.Values[idx], fn)
+		}
+	}
+}
+func inspectStmts(stmts []Stmt, fn func(node any) bool) {
+	for _, stmt := range stmts {
+		Inspect(stmt, fn)
+	}
+}
@@ -0,0 +1,69 @@
+package ast
+
+// Line returns the line where a statement or expression starts, or 0 for
+// nil. Nodes keep only some of their tokens, so it is the line of the
+// first token the node has, looking into its leftmost operand if needed.
+func Line(node any) int {
+	switch node := node.(type) {
+	case *PrintStmt:
+		return node.Keyword.Line
+	case *ExpressionStmt:
+		return Line(node.Expression)
+	case *VariableStmt:
+		return node.Name.Line
+	case *BlockStmt:
+		return node.Brace.Line
+	case *IfStmt:
+		return node.Keyword.Line
+	case *FunctionStmt:
+		return node.Name.Line
+	case *ReturnStmt:
+		return node.Keyword.Line
+	case *ImportStmt:
+		return node.Keyword.Line
+	case *ExportStmt:
+		return node.Keyword.Line
+	case *AssertStmt:
+		return node.Keyword.Line
+	case *TestStmt:
+		return node.Keyword.Line
+	case *LiteralNode:
+		return node.Token.Line
+	case *VariableNode:
+		return node.Name.Line
+	case *AssignNode:
+		return node.Name.Line
+	case *CompoundAssignNode:
+		return Line(node.Target)
+	case *UpdateNode:
+		if node.Prefix {
+			return node.Op.Line
+		}
+		return Line(node.Target)
+	case *BinaryNode:
+		return Line(node.Left)
+	case *UnaryNode:
+		return node.Op.Line
+	case *GroupNode:
+		return Line(node.Expression)
+	case *ConditionNode:
+		return Line(node.Condition)
+	case *CallNode:
+		return Line(node.Callee)
+	case *LambdaNode:
+		return node.Keyword.Line
+	case *GetNode:
+		return Line(node.Object)
+	case *SetNode:
+		return Line(node.Object)
+	case *ListNode:
+		return node.Bracket.Line
+	case *IndexNode:
+		return Line(node.Object)
+	case *SetIndexNode:
+		return Line(node.Object)
+	case *MapNode:
+		return node.Brace.Line
+	}
+	return 0
+}
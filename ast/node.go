@@ -5,22 +5,66 @@ import (
 )
 
 type PrintStmt struct {
-	Value any
+	Keyword token.Token
+	Value   any
 }
 type ExpressionStmt struct {
 	Expression Expr
 }
+
+// VariableStmt declares a binding. Kind is the declaring keyword: VAR,
+// LET or CONST.
 type VariableStmt struct {
+	Kind  token.TokenType
 	Name  token.Token
 	Value Expr
 }
 type BlockStmt struct {
+	Brace token.Token
 	Stmts []Stmt
 }
 type IfStmt struct {
-	Cond Expr
-	Then Stmt
-	Else Stmt
+	Keyword token.Token
+	Cond    Expr
+	Then    Stmt
+	Else    Stmt
+}
+type FunctionStmt struct {
+	Name   token.Token
+	Params []token.Token
+	Body   []Stmt
+}
+type ReturnStmt struct {
+	Keyword token.Token
+	Value   Expr
+}
+
+// ImportStmt is `import "path" as Name;`.
+type ImportStmt struct {
+	Keyword token.Token
+	Path    token.Token
+	Name    token.Token
+}
+
+// ExportStmt marks a top level declaration as visible to importers.
+type ExportStmt struct {
+	Keyword token.Token
+	Decl    Stmt
+}
+
+// AssertStmt is `assert Condition, Message;`. Message may be nil.
+type AssertStmt struct {
+	Keyword   token.Token
+	Condition Expr
+	Message   Expr
+}
+
+// TestStmt is a `test "name" { ... }` block. Normal runs skip it; the test
+// runner executes each one on its own.
+type TestStmt struct {
+	Keyword token.Token
+	Name    token.Token
+	Body    []Stmt
 }
 type VariableNode struct {
 	Name token.Token
@@ -30,6 +74,21 @@ type AssignNode struct {
 	Name  token.Token
 	Value Expr
 }
+
+// CompoundAssignNode is "target op= value". Op keeps the assignment token,
+// e.g. '+=', and Target is any assignable expression.
+type CompoundAssignNode struct {
+	Target Expr
+	Op     token.Token
+	Value  Expr
+}
+
+// UpdateNode is a prefix or postfix '++' or '--' applied to Target.
+type UpdateNode struct {
+	Target Expr
+	Op     token.Token
+	Prefix bool
+}
 type BinaryNode struct {
 	Left  Expr
 	Right Expr
@@ -42,11 +101,60 @@ type UnaryNode struct {
 type GroupNode struct {
 	Expression Expr
 }
+
+// LiteralNode is a number, string, true, false or nil. Token is the
+// literal as written.
 type LiteralNode struct {
+	Token token.Token
 	Value any
 }
+
+// ConditionNode is "Condition ? Truth : False". Question is the '?'.
 type ConditionNode struct {
 	Condition Expr
+	Question  token.Token
 	Truth     Expr
 	False     Expr
 }
+
+type CallNode struct {
+	Callee Expr
+	Paren  token.Token
+	Args   []Expr
+}
+type LambdaNode struct {
+	Keyword token.Token
+	Params  []token.Token
+	Body    []Stmt
+}
+type GetNode struct {
+	Object Expr
+	Name   token.Token
+}
+type ListNode struct {
+	Bracket  token.Token
+	Elements []Expr
+}
+type IndexNode struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+}
+type SetIndexNode struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+	Value   Expr
+}
+
+// MapNode is a map literal. Keys and Values are parallel slices.
+type MapNode struct {
+	Brace  token.Token
+	Keys   []Expr
+	Values []Expr
+}
+type SetNode struct {
+	Object Expr
+	Name   token.Token
+	Value  Expr
+}
@@ -0,0 +1,224 @@
+package ast
+
+import (
+	"fmt"
+	"lox/token"
+	"strconv"
+	"strings"
+)
+
+// SourcePrinter renders an expression back as Lox source on one line, as
+// used in assertion messages. Groups are kept as written, so the result
+// parses to the same tree.
+func SourcePrinter(expr Expr) string {
+	p := &sourcePrinter{}
+	p.expr(expr)
+	return p.sb.String()
+}
+
+type sourcePrinter struct {
+	sb strings.Builder
+}
+
+func (p *sourcePrinter) write(parts ...string) {
+	for _, part := range parts {
+		p.sb.WriteString(part)
+	}
+}
+func (p *sourcePrinter) expr(expr Expr) {
+	switch expr := expr.(type) {
+	case *LiteralNode:
+		p.write(literalSource(expr.Value))
+	case *VariableNode:
+		p.write(expr.Name.Lexeme)
+	case *AssignNode:
+		p.write(expr.Name.Lexeme, " = ")
+		p.expr(expr.Value)
+	case *CompoundAssignNode:
+		p.expr(expr.Target)
+		p.write(" ", expr.Op.Lexeme, " ")
+		p.expr(expr.Value)
+	case *UpdateNode:
+		if expr.Prefix {
+			p.write(expr.Op.Lexeme)
+			p.expr(expr.Target)
+		} else {
+			p.expr(expr.Target)
+			p.write(expr.Op.Lexeme)
+		}
+	case *BinaryNode:
+		p.expr(expr.Left)
+		p.write(" ", expr.Op.Lexeme, " ")
+		p.expr(expr.Right)
+	case *UnaryNode:
+		p.write(expr.Op.Lexeme)
+		p.expr(expr.Right)
+	case *GroupNode:
+		p.write("(")
+		p.expr(expr.Expression)
+		p.write(")")
+	case *ConditionNode:
+		p.expr(expr.Condition)
+		p.write(" ? ")
+		p.expr(expr.Truth)
+		p.write(" : ")
+		p.expr(expr.False)
+	case *CallNode:
+		p.expr(expr.Callee)
+		p.write("(")
+		p.exprs(expr.Args)
+		p.write(")")
+	case *LambdaNode:
+		p.lambda(expr)
+	case *GetNode:
+		p.expr(expr.Object)
+		p.write(".", expr.Name.Lexeme)
+	case *SetNode:
+		p.expr(expr.Object)
+		p.write(".", expr.Name.Lexeme, " = ")
+		p.expr(expr.Value)
+	case *ListNode:
+		p.write("[")
+		p.exprs(expr.Elements)
+		p.write("]")
+	case *IndexNode:
+		p.expr(expr.Object)
+		p.write("[")
+		p.expr(expr.Index)
+		p.write("]")
+	case *SetIndexNode:
+		p.expr(expr.Object)
+		p.write("[")
+		p.expr(expr.Index)
+		p.write("] = ")
+		p.expr(expr.Value)
+	case *MapNode:
+		p.write("{")
+		for idx := range expr.Keys {
+			if idx > 0 {
+				p.write(", ")
+			}
+			p.expr(expr.Keys[idx])
+			p.write(": ")
+			p.expr(expr.Values[idx])
+		}
+		p.write("}")
+	default:
+		p.write(fmt.Sprintf("not a valid node, %+#v", expr))
+	}
+}
+func literalSource(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return `"` + value + `"`
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+func (p *sourcePrinter) exprs(exprs []Expr) {
+	for idx, expr := range exprs {
+		if idx > 0 {
+			p.write(", ")
+		}
+		p.expr(expr)
+	}
+}
+func paramsSource(params []token.Token) string {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, param.Lexeme)
+	}
+	return strings.Join(names, ", ")
+}
+
+// lambda prints an arrow function with an expression body in the short
+// form it was written in, and everything else as 'fun'.
+func (p *sourcePrinter) lambda(expr *LambdaNode) {
+	if expr.Keyword.Typ != token.ARROW {
+		p.write("fun (", paramsSource(expr.Params), ") ")
+		p.block(expr.Body)
+		return
+	}
+	p.write("(", paramsSource(expr.Params), ") => ")
+	if len(expr.Body) == 1 {
+		if ret, ok := expr.Body[0].(*ReturnStmt); ok && ret.Keyword.Typ == token.ARROW {
+			p.expr(ret.Value)
+			return
+		}
+	}
+	p.block(expr.Body)
+}
+func (p *sourcePrinter) block(stmts []Stmt) {
+	if len(stmts) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{ ")
+	for _, stmt := range stmts {
+		p.stmt(stmt)
+		p.write(" ")
+	}
+	p.write("}")
+}
+func (p *sourcePrinter) stmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *PrintStmt:
+		p.write("print ")
+		p.expr(stmt.Value)
+		p.write(";")
+	case *ExpressionStmt:
+		p.expr(stmt.Expression)
+		p.write(";")
+	case *VariableStmt:
+		p.write(strings.ToLower(stmt.Kind.String()), " ", stmt.Name.Lexeme)
+		if stmt.Value != nil {
+			p.write(" = ")
+			p.expr(stmt.Value)
+		}
+		p.write(";")
+	case *BlockStmt:
+		p.block(stmt.Stmts)
+	case *IfStmt:
+		p.write("if (")
+		p.expr(stmt.Cond)
+		p.write(") ")
+		p.stmt(stmt.Then)
+		if stmt.Else == nil {
+			return
+		}
+		p.write(" else ")
+		p.stmt(stmt.Else)
+	case *FunctionStmt:
+		p.write("fun ", stmt.Name.Lexeme, "(", paramsSource(stmt.Params), ") ")
+		p.block(stmt.Body)
+	case *ReturnStmt:
+		p.write("return")
+		if stmt.Value != nil {
+			p.write(" ")
+			p.expr(stmt.Value)
+		}
+		p.write(";")
+	case *ImportStmt:
+		p.write("import ", stmt.Path.Lexeme, " as ", stmt.Name.Lexeme, ";")
+	case *ExportStmt:
+		p.write("export ")
+		p.stmt(stmt.Decl)
+	case *AssertStmt:
+		p.write("assert ")
+		p.expr(stmt.Condition)
+		if stmt.Message != nil {
+			p.write(", ")
+			p.expr(stmt.Message)
+		}
+		p.write(";")
+	case *TestStmt:
+		p.write("test ", stmt.Name.Lexeme, " ")
+		p.block(stmt.Body)
+	default:
+		p.write(fmt.Sprintf("not a valid statement, %+#v", stmt))
+	}
+}
@@ -0,0 +1,247 @@
+// Package coverage records which lines and branches of Lox scripts run.
+// A Profile learns the statements and branches of each file from its
+// source, so that those that never run are reported too, and counts their
+// executions through an interpreter.Observer.
+package coverage
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"lox/ast"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/scanner"
+	"lox/token"
+	"slices"
+)
+
+// Branch is an if statement or a conditional expression.
+type Branch struct {
+	// Line and Column locate the if keyword or the '?', from 1.
+	Line, Column int
+	// Kind is "if" or "?:".
+	Kind string
+	// Taken counts the times the condition held, NotTaken the others.
+	Taken, NotTaken int
+}
+
+// File holds the counts of one file.
+type File struct {
+	// Key is the key of the file for the module loader.
+	Key    string
+	Source string
+	// Lines maps each line where a statement other than a block starts
+	// to the number of statements run on it.
+	Lines    map[int]int
+	Branches []*Branch
+}
+
+// Profile holds the counts of every file seen so far.
+type Profile struct {
+	files map[string]*File
+	// branches finds the branch of an if keyword or '?' by file and
+	// position.
+	branches map[position]*Branch
+}
+
+type position struct {
+	file         string
+	line, column int
+}
+
+func NewProfile() *Profile {
+	return &Profile{files: make(map[string]*File), branches: make(map[position]*Branch)}
+}
+
+// Add learns the statements and branches of the file with the given key
+// from its source. Adding a file again keeps its counts.
+func (p *Profile) Add(key, source string) error {
+	if _, ok := p.files[key]; ok {
+		return nil
+	}
+	er.Errors = er.Errors[:0]
+	stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+	if len(er.Errors) > 0 {
+		err := er.Errors[0]
+		er.Errors = er.Errors[:0]
+		return err
+	}
+	file := &File{Key: key, Source: source, Lines: make(map[int]int)}
+	branch := func(tok token.Token, kind string) {
+		b := &Branch{Line: tok.Line, Column: tok.Column + 1, Kind: kind}
+		file.Branches = append(file.Branches, b)
+		p.branches[position{key, b.Line, b.Column}] = b
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node any) bool {
+			switch node := node.(type) {
+			case *ast.TestStmt:
+				// Test blocks are tests, not code under test.
+				return false
+			case *ast.IfStmt:
+				file.Lines[node.Keyword.Line] = 0
+				branch(node.Keyword, "if")
+			case *ast.ConditionNode:
+				branch(node.Question, "?:")
+			case *ast.PrintStmt, *ast.ExpressionStmt, *ast.VariableStmt, *ast.FunctionStmt, *ast.ReturnStmt,
+				*ast.ImportStmt, *ast.AssertStmt:
+				file.Lines[ast.Line(node)] = 0
+			}
+			return true
+		})
+	}
+	p.files[key] = file
+	return nil
+}
+
+// Files returns the files of the profile sorted by key.
+func (p *Profile) Files() []*File {
+	files := make([]*File, 0, len(p.files))
+	for _, file := range p.files {
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, func(a, b *File) int { return cmp.Compare(a.Key, b.Key) })
+	return files
+}
+
+// Observer returns an observer that counts what interp runs in p. Files
+// it runs that were not added are added from the source read by its
+// module loader, or else left out.
+func (p *Profile) Observer(interp *interpreter.Interpreter) interpreter.Observer {
+	return &recorder{profile: p, interp: interp}
+}
+
+type recorder struct {
+	interpreter.NopObserver
+	profile *Profile
+	interp  *interpreter.Interpreter
+}
+
+func (r *recorder) file() *File {
+	key := r.interp.File()
+	file, ok := r.profile.files[key]
+	if !ok {
+		source, err := r.interp.Loader().Load(key)
+		if err == nil && r.profile.Add(key, source) == nil {
+			return r.profile.files[key]
+		}
+		// Remember that the file cannot be added.
+		r.profile.files[key] = nil
+	}
+	return file
+}
+func (r *recorder) Statement(stmt ast.Stmt, env *interpreter.Environment) error {
+	file := r.file()
+	if file == nil {
+		return nil
+	}
+	switch stmt.(type) {
+	case *ast.BlockStmt, *ast.ExportStmt:
+		// Their statements, and the declaration exported, count instead.
+		return nil
+	}
+	line := ast.Line(stmt)
+	if _, ok := file.Lines[line]; ok {
+		file.Lines[line]++
+	}
+	return nil
+}
+func (r *recorder) Branch(node any, taken bool) {
+	var tok token.Token
+	switch node := node.(type) {
+	case *ast.IfStmt:
+		tok = node.Keyword
+	case *ast.ConditionNode:
+		tok = node.Question
+	default:
+		return
+	}
+	b, ok := r.profile.branches[position{r.interp.File(), tok.Line, tok.Column + 1}]
+	if !ok {
+		return
+	}
+	if taken {
+		b.Taken++
+	} else {
+		b.NotTaken++
+	}
+}
+
+// Coverage returns the number of lines with statements and of branches in
+// a file, and how many of them ran. An if statement or conditional
+// expression has two branches.
+func (f *File) Coverage() (lines, linesRun, branches, branchesRun int) {
+	for _, count := range f.Lines {
+		lines++
+		if count > 0 {
+			linesRun++
+		}
+	}
+	for _, b := range f.Branches {
+		branches += 2
+		if b.Taken > 0 {
+			branchesRun++
+		}
+		if b.NotTaken > 0 {
+			branchesRun++
+		}
+	}
+	return
+}
+
+// WriteProfile writes the counts of p to w, one per line after a header:
+//
+//	line <file>:<line> <count>
+//	branch <file>:<line>.<column> <kind> <taken> <not taken>
+func (p *Profile) WriteProfile(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "mode: count"); err != nil {
+		return err
+	}
+	for _, file := range p.Files() {
+		lines := make([]int, 0, len(file.Lines))
+		for line := range file.Lines {
+			lines = append(lines, line)
+		}
+		slices.Sort(lines)
+		for _, line := range lines {
+			if _, err := fmt.Fprintf(w, "line %s:%d %d\n", file.Key, line, file.Lines[line]); err != nil {
+				return err
+			}
+		}
+		for _, b := range file.Branches {
+			if _, err := fmt.Fprintf(w, "branch %s:%d.%d %s %d %d\n", file.Key, b.Line, b.Column, b.Kind, b.Taken, b.NotTaken); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteSummary writes the line and branch coverage of each file and of
+// all of them to w.
+func (p *Profile) WriteSummary(w io.Writer) error {
+	var lines, linesRun, branches, branchesRun int
+	for _, file := range p.Files() {
+		l, lr, b, br := file.Coverage()
+		lines, linesRun, branches, branchesRun = lines+l, linesRun+lr, branches+b, branchesRun+br
+		if _, err := fmt.Fprintf(w, "%s: %s\n", interpreter.DisplayName(file.Key), summary(l, lr, b, br)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "total: %s\n", summary(lines, linesRun, branches, branchesRun))
+	return err
+}
+func summary(lines, linesRun, branches, branchesRun int) string {
+	return fmt.Sprintf("%s of lines (%d/%d), %s of branches (%d/%d)",
+		percent(linesRun, lines), linesRun, lines, percent(branchesRun, branches), branchesRun, branches)
+}
+func percent(n, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
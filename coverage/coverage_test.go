@@ -0,0 +1,92 @@
+package coverage
+
+import (
+	"io"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/scanner"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const rules = `export fun grade(n) {
+  if (n >= 90) {
+    return "A";
+  } else {
+    return n >= 50 ? "B" : "F";
+  }
+}
+fun unused() {
+  print "never";
+}
+test "grade" {
+  assert grade(10) == "F";
+}
+`
+
+func TestProfile(t *testing.T) {
+	profile := NewProfile()
+	files := fstest.MapFS{"rules.lox": {Data: []byte(rules)}}
+	main := "import \"rules.lox\" as rules;\nprint rules.grade(95);\nprint rules.grade(60);\n"
+	interp := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	interp.SetModuleLoader(interpreter.FSLoader{FS: files})
+	interp.SetFile("main.lox")
+	interp.SetOutput(io.Discard)
+	if err := profile.Add(interp.File(), main); err != nil {
+		t.Fatal(err)
+	}
+	interp.SetObserver(profile.Observer(interp))
+	if _, err := interp.Run(parser.NewParser(scanner.NewSacnner(main).ScanTokens()).Parse()); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := profile.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `mode: count
+line main.lox:1 1
+line main.lox:2 1
+line main.lox:3 1
+line rules.lox:1 1
+line rules.lox:2 2
+line rules.lox:3 1
+line rules.lox:5 1
+line rules.lox:8 1
+line rules.lox:9 0
+branch rules.lox:2.3 if 1 1
+branch rules.lox:5.20 ?: 1 0
+`
+	if out.String() != expected {
+		t.Errorf("expected profile\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := profile.WriteSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"main.lox: 100.0% of lines (3/3), 100.0% of branches (0/0)\n",
+		"rules.lox: 83.3% of lines (5/6), 75.0% of branches (3/4)\n",
+		"total: 88.9% of lines (8/9), 75.0% of branches (3/4)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected summary to contain %q, got\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := profile.WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<span class="line partial" title="?: at column 20: taken 1, not taken 0"><span class="number">5</span><span class="count">1</span>`,
+		`<span class="line missed"><span class="number">9</span><span class="count">0</span>  print &#34;never&#34;;</span>`,
+		`<span class="line "><span class="number">12</span><span class="count"></span>  assert`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the report to contain %s", want)
+		}
+	}
+}
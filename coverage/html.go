@@ -0,0 +1,98 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"lox/interpreter"
+	"strings"
+)
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lox coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { font-family: monospace; line-height: 1.3; }
+.line { display: block; }
+.number, .count { display: inline-block; text-align: right; color: #888; padding-right: 1em; }
+.number { width: 3em; }
+.count { width: 4em; }
+.run { background: #dfd; }
+.partial { background: #ffc; }
+.missed { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Lox coverage</h1>
+<p>{{.Total}}</p>
+<ul>
+{{range $i, $f := .Files}}<li><a href="#file{{$i}}">{{$f.Name}}</a>: {{$f.Summary}}</li>
+{{end}}</ul>
+{{range $i, $f := .Files}}<h2 id="file{{$i}}">{{$f.Name}}</h2>
+<p>{{$f.Summary}}</p>
+<pre>{{range $f.Lines}}<span class="line {{.Class}}"{{if .Title}} title="{{.Title}}"{{end}}><span class="number">{{.Number}}</span><span class="count">{{.Count}}</span>{{.Text}}</span>{{end}}</pre>
+{{end}}</body>
+</html>
+`))
+
+type htmlFile struct {
+	Name, Summary string
+	Lines         []htmlLine
+}
+
+// htmlLine is a line of source. Class is "run", "partial" when a branch
+// on it was never taken, "missed" when its statements never ran and ""
+// when it has none.
+type htmlLine struct {
+	Number      int
+	Count       string
+	Class       string
+	Title, Text string
+}
+
+// WriteHTML writes a report of p to w that shows the source of each file
+// with the lines that ran and the branches that were taken.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	var files []htmlFile
+	var lines, linesRun, branches, branchesRun int
+	for _, file := range p.Files() {
+		l, lr, b, br := file.Coverage()
+		lines, linesRun, branches, branchesRun = lines+l, linesRun+lr, branches+b, branchesRun+br
+		files = append(files, htmlFile{Name: interpreter.DisplayName(file.Key), Summary: summary(l, lr, b, br), Lines: file.htmlLines()})
+	}
+	return page.Execute(w, map[string]any{
+		"Total": "total: " + summary(lines, linesRun, branches, branchesRun),
+		"Files": files,
+	})
+}
+func (f *File) htmlLines() []htmlLine {
+	branches := make(map[int][]*Branch)
+	for _, b := range f.Branches {
+		branches[b.Line] = append(branches[b.Line], b)
+	}
+	var lines []htmlLine
+	for idx, text := range strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n") {
+		line := htmlLine{Number: idx + 1, Text: text}
+		count, ok := f.Lines[line.Number]
+		if ok {
+			line.Count = fmt.Sprint(count)
+			line.Class = "missed"
+			if count > 0 {
+				line.Class = "run"
+			}
+		}
+		var titles []string
+		for _, b := range branches[line.Number] {
+			titles = append(titles, fmt.Sprintf("%s at column %d: taken %d, not taken %d", b.Kind, b.Column, b.Taken, b.NotTaken))
+			if line.Class != "missed" && (b.Taken == 0 || b.NotTaken == 0) {
+				line.Class = "partial"
+			}
+		}
+		line.Title = strings.Join(titles, "; ")
+		lines = append(lines, line)
+	}
+	return lines
+}
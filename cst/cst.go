@@ -0,0 +1,319 @@
+// Package cst builds a concrete syntax tree of Lox source for the
+// formatter. Unlike the ast, the tree keeps every token, and every comment
+// stays attached to the token next to it, so printing the tree gives back
+// all of the source.
+package cst
+
+import (
+	"fmt"
+	"lox/token"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Program Kind = iota
+	Import
+	Export
+	Var
+	Fun
+	Test
+	If
+	Print
+	Return
+	Assert
+	Block
+	ExprStmt
+	// Expr is a flat run of operands separated by binary, assignment and
+	// conditional operators. Precedence does not change how they print.
+	Expr
+	// Operand is a primary expression with its prefix operators and its
+	// calls, indexes, property gets and postfix operators.
+	Operand
+	Group
+	List
+	Map
+	Lambda
+	Arrow
+	Params
+	Args
+	Index
+	Get
+)
+
+// Element is a *Node or a *Leaf.
+type Element interface {
+	element()
+}
+
+// Node is an inner node of the tree. Children hold its tokens and sub
+// nodes in source order.
+type Node struct {
+	Kind     Kind
+	Children []Element
+}
+
+// Leaf is a token with the comments around it. Leading holds the comments
+// before the token since the previous one ended, and Trailing a comment
+// that follows the token on the line where it ends.
+type Leaf struct {
+	Token    *token.Token
+	Leading  []*token.Token
+	Trailing *token.Token
+}
+
+func (*Node) element() {}
+func (*Leaf) element() {}
+
+// StartLine returns the line where tok begins. Token lines are those of
+// the last character, which differ for multi-line strings and comments.
+func StartLine(tok *token.Token) int {
+	return tok.Line - strings.Count(tok.Lexeme, "\n")
+}
+
+// Parse builds the tree of a program from tokens scanned with comments.
+// The program must be free of compile errors, so check it with the parser
+// and resolver first.
+func Parse(tokens []*token.Token) (root *Node, err error) {
+	p := &parser{leaves: attachComments(tokens)}
+	defer func() {
+		if r := recover(); r != nil {
+			leaf := p.peek()
+			root, err = nil, fmt.Errorf("[line %d] unexpected '%s' (%v)", leaf.Token.Line, leaf.Token.Lexeme, r)
+		}
+	}()
+	root = &Node{Kind: Program}
+	for !p.check(token.EOF) {
+		root.Children = append(root.Children, p.declaration())
+	}
+	root.Children = append(root.Children, p.advance())
+	return root, nil
+}
+
+// attachComments turns tokens into leaves. A comment that starts on the
+// line where the previous token ends trails that token; any other comment
+// leads the next one.
+func attachComments(tokens []*token.Token) []*Leaf {
+	leaves := make([]*Leaf, 0, len(tokens))
+	var leading []*token.Token
+	for _, tok := range tokens {
+		if tok.Typ != token.COMMENT {
+			leaves = append(leaves, &Leaf{Token: tok, Leading: leading})
+			leading = nil
+			continue
+		}
+		if len(leaves) > 0 && len(leading) == 0 {
+			prev := leaves[len(leaves)-1]
+			if prev.Trailing == nil && prev.Token.Line == StartLine(tok) {
+				prev.Trailing = tok
+				continue
+			}
+		}
+		leading = append(leading, tok)
+	}
+	return leaves
+}
+
+type parser struct {
+	leaves  []*Leaf
+	current int
+}
+
+func (p *parser) declaration() *Node {
+	switch {
+	case p.check(token.IMPORT):
+		return p.node(Import, p.advance(), p.expect(token.STRING), p.expect(token.IDENTIFIER), p.expect(token.IDENTIFIER), p.expect(token.SEMICOLON))
+	case p.check(token.EXPORT):
+		return p.node(Export, p.advance(), p.declaration())
+	case p.check(token.VAR), p.check(token.LET), p.check(token.CONST):
+		node := p.node(Var, p.advance(), p.expect(token.IDENTIFIER))
+		if p.check(token.EQUAL) {
+			node.Children = append(node.Children, p.advance(), p.expr(true))
+		}
+		node.Children = append(node.Children, p.expect(token.SEMICOLON))
+		return node
+	case p.check(token.FUN) && p.checkNext(token.IDENTIFIER):
+		return p.node(Fun, p.advance(), p.advance(), p.params(), p.block())
+	case p.check(token.IDENTIFIER) && p.peek().Token.Lexeme == "test" && p.checkNext(token.STRING):
+		return p.node(Test, p.advance(), p.advance(), p.block())
+	}
+	return p.statement()
+}
+func (p *parser) statement() *Node {
+	switch {
+	case p.check(token.IF):
+		node := p.node(If, p.advance(), p.expect(token.LEFT_PAREN), p.expr(true), p.expect(token.RIGHT_PAREN), p.statement())
+		if p.check(token.ELSE) {
+			node.Children = append(node.Children, p.advance(), p.statement())
+		}
+		return node
+	case p.check(token.PRINT):
+		return p.node(Print, p.advance(), p.expr(true), p.expect(token.SEMICOLON))
+	case p.check(token.RETURN):
+		node := p.node(Return, p.advance())
+		if !p.check(token.SEMICOLON) {
+			node.Children = append(node.Children, p.expr(true))
+		}
+		node.Children = append(node.Children, p.expect(token.SEMICOLON))
+		return node
+	case p.check(token.ASSERT):
+		node := p.node(Assert, p.advance(), p.expr(false))
+		if p.check(token.COMMA) {
+			node.Children = append(node.Children, p.advance(), p.expr(false))
+		}
+		node.Children = append(node.Children, p.expect(token.SEMICOLON))
+		return node
+	case p.check(token.LEFT_BRACE):
+		return p.block()
+	}
+	return p.node(ExprStmt, p.expr(true), p.expect(token.SEMICOLON))
+}
+func (p *parser) block() *Node {
+	node := p.node(Block, p.expect(token.LEFT_BRACE))
+	for !p.check(token.RIGHT_BRACE) {
+		node.Children = append(node.Children, p.declaration())
+	}
+	node.Children = append(node.Children, p.advance())
+	return node
+}
+
+// binaryOps are the operators that may follow an operand in an Expr.
+var binaryOps = map[token.TokenType]bool{
+	token.EQUAL: true, token.PLUS_EQUAL: true, token.MINUS_EQUAL: true,
+	token.STAR_EQUAL: true, token.SLASH_EQUAL: true, token.PERCENT_EQUAL: true,
+	token.EQUAL_EQUAL: true, token.BANG_EQUAL: true,
+	token.LESS: true, token.LESS_EQUAL: true, token.GREATER: true, token.GREATER_EQUAL: true,
+	token.PIPE: true, token.CARET: true, token.AMPERSAND: true,
+	token.LESS_LESS: true, token.GREATER_GREATER: true,
+	token.PLUS: true, token.MINUS: true,
+	token.STAR: true, token.SLASH: true, token.PERCENT: true, token.TILDE_SLASH: true,
+	token.STAR_STAR: true, token.QUESTION_MARK: true,
+}
+
+// expr parses operands joined by operators. A ':' belongs to the
+// expression only while a '?' is open, so that it ends a map key, and ','
+// only where the comma operator is allowed.
+func (p *parser) expr(comma bool) *Node {
+	node := p.node(Expr, p.operand())
+	conditions := 0
+	for {
+		switch {
+		case binaryOps[p.peek().Token.Typ]:
+			if p.check(token.QUESTION_MARK) {
+				conditions++
+			}
+		case p.check(token.COLON) && conditions > 0:
+			conditions--
+		case p.check(token.COMMA) && comma:
+		default:
+			return node
+		}
+		node.Children = append(node.Children, p.advance(), p.operand())
+	}
+}
+func (p *parser) operand() *Node {
+	node := p.node(Operand)
+	for p.check(token.MINUS) || p.check(token.BANG) || p.check(token.TILDE) || p.check(token.PLUS_PLUS) || p.check(token.MINUS_MINUS) {
+		node.Children = append(node.Children, p.advance())
+	}
+	node.Children = append(node.Children, p.primary())
+	for {
+		switch {
+		case p.check(token.LEFT_PAREN):
+			node.Children = append(node.Children, p.sequence(Args, token.RIGHT_PAREN))
+		case p.check(token.LEFT_BRACKET):
+			node.Children = append(node.Children, p.node(Index, p.advance(), p.expr(false), p.expect(token.RIGHT_BRACKET)))
+		case p.check(token.DOT):
+			node.Children = append(node.Children, p.node(Get, p.advance(), p.expect(token.IDENTIFIER)))
+		case p.check(token.PLUS_PLUS), p.check(token.MINUS_MINUS):
+			node.Children = append(node.Children, p.advance())
+		default:
+			return node
+		}
+	}
+}
+func (p *parser) primary() Element {
+	switch {
+	case p.check(token.FUN):
+		return p.node(Lambda, p.advance(), p.params(), p.block())
+	case p.check(token.LEFT_BRACKET):
+		return p.sequence(List, token.RIGHT_BRACKET)
+	case p.check(token.LEFT_BRACE):
+		return p.sequence(Map, token.RIGHT_BRACE)
+	case p.check(token.LEFT_PAREN) && p.isArrow():
+		node := p.node(Arrow, p.params(), p.expect(token.ARROW))
+		if p.check(token.LEFT_BRACE) {
+			node.Children = append(node.Children, p.block())
+		} else {
+			node.Children = append(node.Children, p.expr(false))
+		}
+		return node
+	case p.check(token.LEFT_PAREN):
+		return p.node(Group, p.advance(), p.expr(true), p.expect(token.RIGHT_PAREN))
+	}
+	return p.advance()
+}
+func (p *parser) params() *Node {
+	node := p.node(Params, p.expect(token.LEFT_PAREN))
+	for !p.check(token.RIGHT_PAREN) {
+		node.Children = append(node.Children, p.expect(token.IDENTIFIER))
+		if p.check(token.COMMA) {
+			node.Children = append(node.Children, p.advance())
+		}
+	}
+	node.Children = append(node.Children, p.advance())
+	return node
+}
+
+// sequence parses the elements of a list, map or argument list between
+// the current bracket and close. Map entries are a key, ':' and a value.
+func (p *parser) sequence(kind Kind, close token.TokenType) *Node {
+	node := p.node(kind, p.advance())
+	for !p.check(close) {
+		node.Children = append(node.Children, p.expr(false))
+		if kind == Map {
+			node.Children = append(node.Children, p.expect(token.COLON), p.expr(false))
+		}
+		if !p.check(token.COMMA) {
+			break
+		}
+		node.Children = append(node.Children, p.advance())
+	}
+	node.Children = append(node.Children, p.expect(close))
+	return node
+}
+
+// isArrow looks ahead from a '(' for a parameter list followed by '=>'.
+func (p *parser) isArrow() bool {
+	i := p.current + 1
+	for p.leaves[i].Token.Typ == token.IDENTIFIER || p.leaves[i].Token.Typ == token.COMMA {
+		i++
+	}
+	return p.leaves[i].Token.Typ == token.RIGHT_PAREN && p.leaves[i+1].Token.Typ == token.ARROW
+}
+func (p *parser) node(kind Kind, children ...Element) *Node {
+	return &Node{Kind: kind, Children: children}
+}
+func (p *parser) expect(typ token.TokenType) *Leaf {
+	if !p.check(typ) {
+		panic(fmt.Sprintf("expected %s", typ))
+	}
+	return p.advance()
+}
+func (p *parser) check(typ token.TokenType) bool {
+	return p.peek().Token.Typ == typ
+}
+func (p *parser) checkNext(typ token.TokenType) bool {
+	return p.current+1 < len(p.leaves) && p.leaves[p.current+1].Token.Typ == typ
+}
+func (p *parser) peek() *Leaf {
+	return p.leaves[p.current]
+}
+func (p *parser) advance() *Leaf {
+	leaf := p.leaves[p.current]
+	if leaf.Token.Typ != token.EOF {
+		p.current++
+	}
+	return leaf
+}
@@ -0,0 +1,73 @@
+package cst
+
+import (
+	"lox/scanner"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{
+			source:   `var a=1+2*3;print a;`,
+			expected: "var a = 1 + 2 * 3;\nprint a;\n",
+		},
+		{
+			source:   "fun f(a,b){return a>b?a:b;}",
+			expected: "fun f(a, b) {\n  return a > b ? a : b;\n}\n",
+		},
+		{
+			source:   "if(a)print 1;else{print 2;}",
+			expected: "if (a) print 1;\nelse {\n  print 2;\n}\n",
+		},
+		{
+			source:   "var a = 1; // one\n\n\n// two\nvar b = 2;",
+			expected: "var a = 1; // one\n\n// two\nvar b = 2;\n",
+		},
+		{
+			source:   "print /* inline */ x;",
+			expected: "print /* inline */ x;\n",
+		},
+		{
+			source:   "var l = [\n1, // one\n// two\n2];",
+			expected: "var l = [\n  1, // one\n  // two\n  2\n];\n",
+		},
+		{
+			source:   "f(1, // one\n2);",
+			expected: "f(1, // one\n  2);\n",
+		},
+		{
+			source:   "{\n  x;\n  // last\n}",
+			expected: "{\n  x;\n  // last\n}\n",
+		},
+		{
+			source:   "var m={\"a\":1,\"b\":(x)=>x};",
+			expected: "var m = {\"a\": 1, \"b\": (x) => x};\n",
+		},
+		{
+			source:   "print - -1;x++;",
+			expected: "print - -1;\nx++;\n",
+		},
+		{
+			source:   "test \"t\" {assert 1==1,\"msg\";}",
+			expected: "test \"t\" {\n  assert 1 == 1, \"msg\";\n}\n",
+		},
+	}
+	for _, test := range tests {
+		root, err := Parse(scanner.NewSacnner(test.source).KeepComments().ScanTokens())
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		if actual := Format(root); actual != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.source, test.expected, actual)
+			continue
+		}
+		root, _ = Parse(scanner.NewSacnner(test.expected).KeepComments().ScanTokens())
+		if again := Format(root); again != test.expected {
+			t.Errorf("%q: formatting twice gives %q", test.source, again)
+		}
+	}
+}
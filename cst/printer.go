@@ -0,0 +1,294 @@
+package cst
+
+import (
+	"lox/token"
+	"strings"
+)
+
+// Format prints the tree in the standard layout: two spaces of indent,
+// one statement per line, opening braces on the line of their statement
+// and spaces around binary operators. Comments are kept where they were,
+// and at most one blank line is kept between statements. A list, map or
+// argument list whose first element starts on a new line is printed with
+// one element per line.
+func Format(root *Node) string {
+	p := &printer{}
+	p.statements(root.Children[:len(root.Children)-1])
+	p.comments(root.Children[len(root.Children)-1].(*Leaf))
+	p.sb.WriteString("\n")
+	return strings.TrimLeft(p.sb.String(), "\n")
+}
+
+// Breaks requested between tokens. The strongest one requested since the
+// last token is written before the next.
+const (
+	none = iota
+	space
+	newline
+	blank
+)
+
+type printer struct {
+	sb     strings.Builder
+	indent int
+	// exprs counts the expressions being printed. A line broken by a
+	// comment inside one is continued with an extra indent.
+	exprs int
+	// pending is the break to write before the next token, and
+	// pendingIndent the indent of the line it starts.
+	pending       int
+	pendingIndent int
+	// lastLine is the source line where the last written token ended.
+	lastLine int
+}
+
+func (p *printer) request(brk int) {
+	p.pending = max(p.pending, brk)
+	if brk >= newline {
+		p.pendingIndent = p.indent + min(p.exprs, 1)
+	}
+}
+func (p *printer) write(text string) {
+	switch p.pending {
+	case space:
+		p.sb.WriteString(" ")
+	case none:
+		// Keep a prefix '-' from merging with the next into '--'.
+		if strings.HasSuffix(p.sb.String(), "-") && strings.HasPrefix(text, "-") {
+			p.sb.WriteString(" ")
+		}
+	case newline, blank:
+		if p.pending == blank {
+			p.sb.WriteString("\n")
+		}
+		p.sb.WriteString("\n")
+		p.sb.WriteString(strings.Repeat("  ", p.pendingIndent))
+	}
+	p.pending = none
+	p.sb.WriteString(text)
+}
+
+// comments writes the comments leading leaf. One that starts a line in
+// the source starts a line here too, after a blank line if there was one.
+// Lines started by the comments keep the indent of the first.
+func (p *printer) comments(leaf *Leaf) {
+	broken, indent := p.pending >= newline, p.pendingIndent
+	for _, comment := range leaf.Leading {
+		p.breakBefore(comment)
+		p.comment(comment)
+		if broken {
+			p.pendingIndent = indent
+		}
+	}
+	if len(leaf.Leading) > 0 {
+		p.breakBefore(leaf.Token)
+	}
+}
+
+// breakBefore requests the break that comes before tok in the source. A
+// line break already requested keeps its indent.
+func (p *printer) breakBefore(tok *token.Token) {
+	brk := space
+	switch start := StartLine(tok); {
+	case p.lastLine == 0:
+		return
+	case start > p.lastLine+1:
+		brk = blank
+	case start > p.lastLine:
+		brk = newline
+	}
+	if p.pending >= newline {
+		p.pending = max(p.pending, brk)
+	} else {
+		p.request(brk)
+	}
+}
+func (p *printer) comment(comment *token.Token) {
+	p.write(strings.TrimRight(comment.Lexeme, " \t\r"))
+	p.lastLine = comment.Line
+	if strings.HasPrefix(comment.Lexeme, "//") {
+		p.request(newline)
+	}
+}
+
+// token writes leaf with its comments.
+func (p *printer) token(leaf *Leaf) {
+	p.comments(leaf)
+	p.bare(leaf)
+}
+
+// bare writes leaf and its trailing comment but not the leading ones.
+func (p *printer) bare(leaf *Leaf) {
+	p.write(leaf.Token.Lexeme)
+	p.lastLine = leaf.Token.Line
+	if leaf.Trailing != nil {
+		p.request(space)
+		p.comment(leaf.Trailing)
+	}
+}
+func (p *printer) element(el Element) {
+	if leaf, ok := el.(*Leaf); ok {
+		p.token(leaf)
+	} else {
+		p.node(el.(*Node))
+	}
+}
+
+// statements writes a list of statements, each on its own line.
+func (p *printer) statements(stmts []Element) {
+	for idx, stmt := range stmts {
+		if idx > 0 {
+			p.request(newline)
+			if first := firstLeaf(stmt); len(first.Leading) == 0 && StartLine(first.Token) > p.lastLine+1 {
+				p.request(blank)
+			}
+		}
+		p.element(stmt)
+	}
+}
+func (p *printer) node(node *Node) {
+	children := node.Children
+	switch node.Kind {
+	case Block:
+		p.block(node)
+	case If:
+		p.token(children[0].(*Leaf))
+		p.request(space)
+		p.element(children[1])
+		p.element(children[2])
+		p.element(children[3])
+		p.request(space)
+		p.element(children[4])
+		if len(children) > 5 {
+			if then := children[4].(*Node); then.Kind == Block {
+				p.request(space)
+			} else {
+				p.request(newline)
+			}
+			p.element(children[5])
+			p.request(space)
+			p.element(children[6])
+		}
+	case Import, Export, Var, Fun, Test, Print, Return, Assert, Lambda:
+		// Words are separated by spaces, except before ';' and ',' and
+		// between a function's name and its parameters.
+		for idx, child := range children {
+			if idx > 0 && !isLeafOf(child, token.SEMICOLON, token.COMMA) && !(node.Kind == Fun && idx == 2) {
+				p.request(space)
+			}
+			p.element(child)
+		}
+	case ExprStmt:
+		p.element(children[0])
+		p.element(children[1])
+	case Expr:
+		p.exprs++
+		for idx, child := range children {
+			if idx > 0 && !isLeafOf(child, token.COMMA) {
+				p.request(space)
+			}
+			p.element(child)
+		}
+		p.exprs--
+	case Arrow:
+		p.element(children[0])
+		p.request(space)
+		p.element(children[1])
+		p.request(space)
+		p.element(children[2])
+	case Operand, Group, Index, Get:
+		for _, child := range children {
+			p.element(child)
+		}
+	case Params, Args, List, Map:
+		p.sequence(node)
+	}
+}
+
+// block writes the statements of a block indented, with the closing brace
+// on a line of its own. Comments before the brace are indented with the
+// statements. A block in an expression, as the body of a function, is
+// indented from the line where it starts.
+func (p *printer) block(node *Node) {
+	open := node.Children[0].(*Leaf)
+	close := node.Children[len(node.Children)-1].(*Leaf)
+	stmts := node.Children[1 : len(node.Children)-1]
+	exprs := p.exprs
+	p.exprs = 0
+	defer func() { p.exprs = exprs }()
+	p.token(open)
+	if len(stmts) == 0 && len(close.Leading) == 0 {
+		p.bare(close)
+		return
+	}
+	p.indent++
+	p.request(newline)
+	p.statements(stmts)
+	p.comments(close)
+	p.indent--
+	p.request(newline)
+	p.bare(close)
+}
+
+// sequence writes a bracketed list of elements separated by commas, on
+// one line unless the first element starts on a new line in the source.
+func (p *printer) sequence(node *Node) {
+	open := node.Children[0].(*Leaf)
+	close := node.Children[len(node.Children)-1].(*Leaf)
+	elements := node.Children[1 : len(node.Children)-1]
+	broken := len(close.Leading) > 0 || open.Trailing != nil
+	if len(elements) > 0 {
+		first := firstLeaf(elements[0])
+		broken = broken || len(first.Leading) > 0 || StartLine(first.Token) > open.Token.Line
+	}
+	exprs := p.exprs
+	p.token(open)
+	if broken {
+		p.exprs = 0
+		p.indent++
+		p.request(newline)
+	}
+	for _, element := range elements {
+		switch {
+		case isLeafOf(element, token.COMMA):
+			p.element(element)
+			p.request(space)
+			if broken {
+				p.request(newline)
+			}
+		case isLeafOf(element, token.COLON):
+			p.element(element)
+			p.request(space)
+		default:
+			p.element(element)
+		}
+	}
+	if broken {
+		p.comments(close)
+		p.indent--
+		p.request(newline)
+	}
+	p.exprs = exprs
+	p.bare(close)
+}
+func isLeafOf(el Element, types ...token.TokenType) bool {
+	leaf, ok := el.(*Leaf)
+	if !ok {
+		return false
+	}
+	for _, typ := range types {
+		if leaf.Token.Typ == typ {
+			return true
+		}
+	}
+	return false
+}
+func firstLeaf(el Element) *Leaf {
+	for {
+		node, ok := el.(*Node)
+		if !ok {
+			return el.(*Leaf)
+		}
+		el = node.Children[0]
+	}
+}
@@ -0,0 +1,536 @@
+// Package dap implements a Debug Adapter Protocol server for Lox. It
+// speaks to an editor over a pair of streams, normally stdin and stdout,
+// and runs the program it launches under the debugger on a goroutine of
+// its own. Lox has no threads, so the program is thread 1.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lox/ast"
+	"lox/debugger"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/wire"
+	"path/filepath"
+	"sync"
+)
+
+// Launcher compiles the program at path for a launch request. It returns
+// an interpreter that passes args to the program and writes its output to
+// out, and the statements to run.
+type Launcher func(path string, args []string, out io.Writer) (*interpreter.Interpreter, []ast.Stmt, error)
+
+// exitRuntimeError is the exit code of a program that fails, as with
+// "lox run".
+const exitRuntimeError = 70
+
+const threadID = 1
+
+// Server answers the requests of one client and runs the program it
+// launches.
+type Server struct {
+	in     *bufio.Reader
+	out    io.Writer
+	launch Launcher
+	// writeMu serializes the messages of the server and of the program,
+	// which sends events from its own goroutine.
+	writeMu sync.Mutex
+	seq     int
+
+	linesStartAt1 bool
+	// breakpoints holds the lines of each source, as the client names it,
+	// until there is a debugger to set them in.
+	breakpoints map[string][]int
+	configured  bool
+
+	interp   *interpreter.Interpreter
+	stmts    []ast.Stmt
+	debugger *debugger.Debugger
+	// builtins are the globals the program started with, left out of
+	// the global scope.
+	builtins    map[string]any
+	stopOnEntry bool
+	noDebug     bool
+	// done is closed when the program ends. It is nil until it starts.
+	done chan struct{}
+	// resume passes the action chosen by the client to the stopped
+	// program.
+	resume chan debugger.Action
+
+	// mu guards the state shared with the program's goroutine. The call
+	// stack and the variables may only be read while it is stopped.
+	mu       sync.Mutex
+	stopped  bool
+	quitting bool
+	// references holds what the variables references handed out since
+	// the program stopped point to: environments, lists and maps.
+	// Reference n is at index n-1.
+	references []any
+}
+
+func NewServer(in io.Reader, out io.Writer, launch Launcher) *Server {
+	return &Server{
+		in:            bufio.NewReader(in),
+		out:           out,
+		launch:        launch,
+		linesStartAt1: true,
+		breakpoints:   make(map[string][]int),
+		resume:        make(chan debugger.Action),
+	}
+}
+
+// Run serves requests until the client disconnects or closes the input.
+// A program still running is stopped first.
+func (s *Server) Run() error {
+	defer s.quit()
+	for {
+		body, err := wire.ReadMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		req := &request{}
+		if err := json.Unmarshal(body, req); err != nil {
+			return err
+		}
+		if req.Command == "disconnect" {
+			s.quit()
+			return s.respond(req, nil)
+		}
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers req. Only failures to write are returned; the others are
+// sent to the client.
+func (s *Server) handle(req *request) error {
+	var body any
+	var err error
+	switch req.Command {
+	case "initialize":
+		var args initializeArguments
+		if err = json.Unmarshal(req.Arguments, &args); err != nil {
+			break
+		}
+		if args.LinesStartAt1 != nil {
+			s.linesStartAt1 = *args.LinesStartAt1
+		}
+		if err := s.respond(req, capabilities()); err != nil {
+			return err
+		}
+		// The client sends its breakpoints and configurationDone after
+		// this event.
+		return s.event("initialized", nil)
+	case "launch":
+		var args launchArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			err = s.load(args)
+		}
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.setBreakpoints(args)
+		}
+	case "configurationDone":
+		s.configured = true
+		s.start()
+	case "threads":
+		body = map[string]any{"threads": []thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = s.stackTrace()
+	case "scopes":
+		var args frameArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.scopes(args.FrameID)
+		}
+	case "variables":
+		var args variablesArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.variables(args.VariablesReference)
+		}
+	case "evaluate":
+		var args evaluateArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.evaluate(args)
+		}
+	case "continue":
+		err = s.proceed(debugger.Continue)
+		body = map[string]any{"allThreadsContinued": true}
+	case "next":
+		err = s.proceed(debugger.StepOver)
+	case "stepIn":
+		err = s.proceed(debugger.StepIn)
+	case "stepOut":
+		err = s.proceed(debugger.StepOut)
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.Pause()
+		}
+	case "terminate":
+		s.quit()
+	default:
+		err = fmt.Errorf("unknown command %s", req.Command)
+	}
+	if err != nil {
+		return s.fail(req, err.Error())
+	}
+	return s.respond(req, body)
+}
+func capabilities() any {
+	return map[string]any{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}
+}
+
+// send numbers msg, whose header is h, and writes it.
+func (s *Server) send(h *header, msg any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	h.Seq = s.seq
+	return wire.WriteMessage(s.out, msg)
+}
+func (s *Server) respond(req *request, body any) error {
+	msg := &response{header: header{Type: "response"}, RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body}
+	return s.send(&msg.header, msg)
+}
+func (s *Server) fail(req *request, message string) error {
+	msg := &response{header: header{Type: "response"}, RequestSeq: req.Seq, Command: req.Command, Message: message}
+	return s.send(&msg.header, msg)
+}
+func (s *Server) event(name string, body any) error {
+	msg := &event{header: header{Type: "event"}, Event: name, Body: body}
+	return s.send(&msg.header, msg)
+}
+
+// output sends what the program writes as output events.
+type output struct {
+	server   *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if err := o.server.event("output", map[string]any{"category": o.category, "output": string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// load compiles the program of a launch request. It starts once the
+// client is done configuring it.
+func (s *Server) load(args launchArguments) error {
+	if s.interp != nil {
+		return errors.New("a program has already been launched")
+	}
+	if args.Program == "" {
+		return errors.New("no program to launch")
+	}
+	interp, stmts, err := s.launch(args.Program, args.Args, &output{server: s, category: "stdout"})
+	if err != nil {
+		return err
+	}
+	s.interp, s.stmts = interp, stmts
+	s.stopOnEntry, s.noDebug = args.StopOnEntry, args.NoDebug
+	s.builtins = make(map[string]any)
+	globals := interp.Globals()
+	for _, name := range globals.Names() {
+		s.builtins[name], _ = globals.Lookup(name)
+	}
+	// Without debugging the debugger is still attached, so that the
+	// program can be stopped, but it has no breakpoints.
+	s.debugger = debugger.New(interp, s)
+	interp.SetObserver(s.debugger)
+	if !s.noDebug {
+		for path, lines := range s.breakpoints {
+			if err := s.applyBreakpoints(path, lines); err != nil {
+				return err
+			}
+		}
+	}
+	s.start()
+	return nil
+}
+
+// start runs the program once it is launched and configured.
+func (s *Server) start() {
+	if s.interp == nil || !s.configured || s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		_, err := s.interp.Run(s.stmts)
+		var exit *interpreter.Exit
+		var runtimeErr *er.RuntimeError
+		code := 0
+		switch {
+		case err == nil, errors.Is(err, debugger.ErrQuit):
+		case errors.As(err, &exit):
+			code = exit.Code
+		case errors.As(err, &runtimeErr):
+			s.event("output", map[string]any{"category": "stderr", "output": runtimeErr.Error() + "\n"})
+			code = exitRuntimeError
+		default:
+			s.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+			code = exitRuntimeError
+		}
+		s.event("exited", map[string]any{"exitCode": code})
+		s.event("terminated", nil)
+	}()
+}
+
+// quit stops the program, if it runs, and waits for it to end.
+func (s *Server) quit() {
+	if s.done == nil {
+		return
+	}
+	s.mu.Lock()
+	s.quitting = true
+	stopped := s.stopped
+	s.stopped = false
+	s.mu.Unlock()
+	if stopped {
+		s.resume <- debugger.Quit
+	} else {
+		s.debugger.Pause()
+	}
+	<-s.done
+}
+
+// Stopped tells the client that the program stopped and waits for it to
+// choose how to go on.
+func (s *Server) Stopped(reason string) debugger.Action {
+	s.mu.Lock()
+	if s.quitting {
+		s.mu.Unlock()
+		return debugger.Quit
+	}
+	if s.noDebug {
+		s.mu.Unlock()
+		return debugger.Continue
+	}
+	if reason == debugger.ReasonEntry && !s.stopOnEntry {
+		// The first statement is only worth a stop if it has a
+		// breakpoint.
+		frame := s.debugger.Frames()[0]
+		if !s.debugger.Breakpoint(frame.File, frame.Line) {
+			s.mu.Unlock()
+			return debugger.Continue
+		}
+		reason = debugger.ReasonBreakpoint
+	}
+	s.stopped = true
+	s.references = nil
+	s.mu.Unlock()
+	s.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	return <-s.resume
+}
+
+// proceed resumes the stopped program with action.
+func (s *Server) proceed(action debugger.Action) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.mu.Unlock()
+		return errors.New("the program is not stopped")
+	}
+	s.stopped = false
+	s.mu.Unlock()
+	s.resume <- action
+	return nil
+}
+
+// frames returns the call stack of the stopped program, innermost last.
+func (s *Server) frames() ([]*debugger.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, errors.New("the program is not stopped")
+	}
+	return s.debugger.Frames(), nil
+}
+
+// frame returns the frame with the given ID, which is its index in the
+// call stack plus one, or the innermost frame for 0.
+func (s *Server) frame(id int) (*debugger.Frame, int, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, 0, err
+	}
+	if id == 0 {
+		id = len(frames)
+	}
+	if id < 1 || id > len(frames) {
+		return nil, 0, fmt.Errorf("no frame %d", id)
+	}
+	return frames[id-1], id - 1, nil
+}
+func (s *Server) setBreakpoints(args setBreakpointsArguments) (any, error) {
+	lines := make([]int, 0, len(args.Breakpoints))
+	breakpoints := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		lines = append(lines, s.fromClient(bp.Line))
+		breakpoints = append(breakpoints, breakpoint{Verified: true, Line: bp.Line})
+	}
+	s.breakpoints[args.Source.Path] = lines
+	if s.debugger != nil && !s.noDebug {
+		if err := s.applyBreakpoints(args.Source.Path, lines); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+// applyBreakpoints replaces the breakpoints of the file at path with lines.
+func (s *Server) applyBreakpoints(path string, lines []int) error {
+	key, err := s.interp.FileKey(path)
+	if err != nil {
+		return err
+	}
+	s.debugger.ClearBreakpoints(key)
+	for _, line := range lines {
+		s.debugger.SetBreakpoint(key, line, true)
+	}
+	return nil
+}
+func (s *Server) stackTrace() (any, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+	stack := make([]stackFrame, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		stack = append(stack, stackFrame{
+			ID:     i + 1,
+			Name:   frame.Name,
+			Source: source{Name: filepath.Base(frame.File), Path: frame.File},
+			Line:   s.toClient(frame.Line),
+			Column: s.toClient(1),
+		})
+	}
+	return map[string]any{"stackFrames": stack, "totalFrames": len(stack)}, nil
+}
+
+// scopes returns a scope for each environment of a frame, from the
+// innermost out to the globals.
+func (s *Server) scopes(id int) (any, error) {
+	frame, _, err := s.frame(id)
+	if err != nil {
+		return nil, err
+	}
+	globals := s.interp.Globals()
+	scopes := make([]scope, 0)
+	depth := 0
+	for env := frame.Env; env != nil; env = env.Enclosing() {
+		switch {
+		case env == globals:
+			scopes = append(scopes, scope{Name: "Globals", VariablesReference: s.reference(env), Expensive: true})
+		case depth == 0:
+			scopes = append(scopes, scope{Name: "Locals", VariablesReference: s.reference(env)})
+		default:
+			scopes = append(scopes, scope{Name: fmt.Sprintf("Scope %d", depth), VariablesReference: s.reference(env)})
+		}
+		depth++
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+// reference returns a variables reference to the children of value, or 0
+// if it has none.
+func (s *Server) reference(value any) int {
+	switch value.(type) {
+	case *interpreter.Environment, *interpreter.List, *interpreter.Map:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.references = append(s.references, value)
+		return len(s.references)
+	}
+	return 0
+}
+
+// variables lists the variables of an environment, the elements of a
+// list or the entries of a map.
+func (s *Server) variables(ref int) (any, error) {
+	if _, err := s.frames(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if ref < 1 || ref > len(s.references) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("no variables reference %d", ref)
+	}
+	value := s.references[ref-1]
+	s.mu.Unlock()
+	variables := make([]variable, 0)
+	add := func(name string, value any) {
+		variables = append(variables, variable{
+			Name:               name,
+			Value:              interpreter.Repr(value),
+			Type:               interpreter.TypeName(value),
+			VariablesReference: s.reference(value),
+		})
+	}
+	switch value := value.(type) {
+	case *interpreter.Environment:
+		globals := value == s.interp.Globals()
+		for _, name := range value.Names() {
+			v, _ := value.Lookup(name)
+			if builtin, ok := s.builtins[name]; ok && globals && builtin == v {
+				continue
+			}
+			add(name, v)
+		}
+	case *interpreter.List:
+		for i, element := range value.Elements() {
+			add(fmt.Sprint(i), element)
+		}
+	case *interpreter.Map:
+		for _, key := range value.Keys() {
+			v, _ := value.Get(key)
+			add(interpreter.Repr(key), v)
+		}
+	}
+	return map[string]any{"variables": variables}, nil
+}
+
+// evaluate evaluates an expression in a frame of the stopped program.
+func (s *Server) evaluate(args evaluateArguments) (any, error) {
+	_, index, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.debugger.Evaluate(args.Expression, index)
+	var runtimeErr *er.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return nil, errors.New(runtimeErr.Msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return evaluateResult{Result: interpreter.Repr(value), Type: interpreter.TypeName(value), VariablesReference: s.reference(value)}, nil
+}
+
+// toClient and fromClient convert line and column numbers, which start
+// at 1 in Lox, to and from those of the client.
+func (s *Server) toClient(n int) int {
+	if s.linesStartAt1 {
+		return n
+	}
+	return n - 1
+}
+func (s *Server) fromClient(n int) int {
+	if s.linesStartAt1 {
+		return n
+	}
+	return n + 1
+}
@@ -0,0 +1,276 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"lox/ast"
+	"lox/errors"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/resolver"
+	"lox/scanner"
+	"lox/wire"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const program = `var total = 0;
+fun add(n) {
+  if (n > 0) {
+    var items = [n, {"k": n}];
+    total = total + n;
+  }
+  return total;
+}
+add(1);
+print add(2);
+`
+
+func launch(path string, args []string, out io.Writer) (*interpreter.Interpreter, []ast.Stmt, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	errors.Errors = errors.Errors[:0]
+	stmts := parser.NewParser(scanner.NewSacnner(string(bs)).ScanTokens()).Parse()
+	resolver.NewResolver().Resolve(stmts)
+	if len(errors.Errors) > 0 {
+		return nil, nil, errors.Errors[0]
+	}
+	interp := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	interp.SetOutput(out)
+	interp.SetFile(path)
+	interp.SetArgs(args)
+	return interp, stmts, nil
+}
+
+// reply is a response or an event from the server.
+type reply struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client talks to a server running on another goroutine. Its messages
+// are read as they come, as the server may write several in a row, and
+// events that arrive while it waits for a response are kept for wait.
+type client struct {
+	t       *testing.T
+	w       *io.PipeWriter
+	replies chan reply
+	seq     int
+	// events holds the events received so far, of which wait has seen
+	// those before seen.
+	events []reply
+	seen   int
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, replies: make(chan reply, 100), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(inR, outW, launch).Run()
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.replies)
+		r := bufio.NewReader(outR)
+		for {
+			body, err := wire.ReadMessage(r)
+			if err != nil {
+				return
+			}
+			var msg reply
+			json.Unmarshal(body, &msg)
+			c.replies <- msg
+		}
+	}()
+	return c
+}
+func (c *client) read() reply {
+	c.t.Helper()
+	r, ok := <-c.replies
+	if !ok {
+		c.t.Fatal("the server stopped writing")
+	}
+	return r
+}
+
+// request sends a request and returns the response to it.
+func (c *client) request(command string, args any) reply {
+	c.t.Helper()
+	c.seq++
+	if err := wire.WriteMessage(c.w, map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		r := c.read()
+		if r.Type == "response" && r.RequestSeq == c.seq {
+			return r
+		}
+		c.events = append(c.events, r)
+	}
+}
+
+// wait returns the body of the next event with the given name, skipping
+// the others.
+func (c *client) wait(name string) string {
+	c.t.Helper()
+	for {
+		for c.seen < len(c.events) {
+			r := c.events[c.seen]
+			c.seen++
+			if r.Event == name {
+				return string(r.Body)
+			}
+		}
+		c.events = append(c.events, c.read())
+	}
+}
+
+// output returns the text of the output events received so far.
+func (c *client) output(category string) string {
+	var text strings.Builder
+	for _, r := range c.events {
+		var body struct{ Category, Output string }
+		if r.Event == "output" && json.Unmarshal(r.Body, &body) == nil && body.Category == category {
+			text.WriteString(body.Output)
+		}
+	}
+	return text.String()
+}
+func (c *client) close() {
+	c.t.Helper()
+	c.w.Close()
+	if err := <-c.done; err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func expect(t *testing.T, what, got string, wants ...string) {
+	t.Helper()
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s: expected %s to contain %s", what, got, want)
+		}
+	}
+}
+func body(t *testing.T, r reply) string {
+	t.Helper()
+	if !r.Success {
+		t.Fatalf("request failed: %s", r.Message)
+	}
+	return string(r.Body)
+}
+
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "add.lox")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	expect(t, "initialize", body(t, c.request("initialize", map[string]any{"adapterID": "lox"})), `"supportsConfigurationDoneRequest":true`)
+	c.wait("initialized")
+	body(t, c.request("launch", map[string]any{"program": path}))
+	expect(t, "setBreakpoints", body(t, c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []any{map[string]any{"line": 5}},
+	})), `"verified":true,"line":5`)
+	body(t, c.request("configurationDone", nil))
+
+	expect(t, "stop", c.wait("stopped"), `"reason":"breakpoint"`, `"threadId":1`)
+	expect(t, "stackTrace", body(t, c.request("stackTrace", map[string]any{"threadId": 1})),
+		`{"id":2,"name":"add","source":{"name":"add.lox","path":"`+path+`"},"line":5,"column":1}`,
+		`{"id":1,"name":"\u003cscript\u003e"`, `"line":9`)
+
+	var scopes struct {
+		Scopes []scope `json:"scopes"`
+	}
+	json.Unmarshal([]byte(body(t, c.request("scopes", map[string]any{"frameId": 2}))), &scopes)
+	names := make([]string, 0, len(scopes.Scopes))
+	for _, s := range scopes.Scopes {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "Locals,Scope 1,Globals" {
+		t.Fatalf("unexpected scopes %v", names)
+	}
+	var locals struct {
+		Variables []variable `json:"variables"`
+	}
+	json.Unmarshal([]byte(body(t, c.request("variables", map[string]any{"variablesReference": scopes.Scopes[0].VariablesReference}))), &locals)
+	if len(locals.Variables) != 1 || locals.Variables[0].Value != `[1, {"k": 1}]` || locals.Variables[0].Type != "list" {
+		t.Fatalf("unexpected locals %+v", locals.Variables)
+	}
+	expect(t, "list", body(t, c.request("variables", map[string]any{"variablesReference": locals.Variables[0].VariablesReference})),
+		`{"name":"0","value":"1","type":"number","variablesReference":0}`, `{"name":"1","value":"{\"k\": 1}","type":"map"`)
+	expect(t, "params", body(t, c.request("variables", map[string]any{"variablesReference": scopes.Scopes[1].VariablesReference})), `"name":"n","value":"1"`)
+	globals := body(t, c.request("variables", map[string]any{"variablesReference": scopes.Scopes[2].VariablesReference}))
+	expect(t, "globals", globals, `"name":"total","value":"0"`, `"name":"add","value":"\u003cfn add\u003e"`)
+	if strings.Contains(globals, "clock") {
+		t.Errorf("expected the builtins to be left out, got %s", globals)
+	}
+	expect(t, "evaluate", body(t, c.request("evaluate", map[string]any{"expression": "n + 10", "frameId": 2})), `"result":"11"`)
+	expect(t, "evaluate in caller", c.request("evaluate", map[string]any{"expression": "n", "frameId": 1}).Message, "Undefined variable 'n'")
+
+	body(t, c.request("next", map[string]any{"threadId": 1}))
+	expect(t, "step", c.wait("stopped"), `"reason":"step"`)
+	expect(t, "after step", body(t, c.request("stackTrace", map[string]any{"threadId": 1})), `"line":7`)
+	body(t, c.request("continue", map[string]any{"threadId": 1}))
+	c.wait("stopped")
+	expect(t, "second call", body(t, c.request("evaluate", map[string]any{"expression": "n"})), `"result":"2"`)
+	body(t, c.request("continue", map[string]any{"threadId": 1}))
+	expect(t, "exited", c.wait("exited"), `"exitCode":0`)
+	c.wait("terminated")
+	if out := c.output("stdout"); out != "3\n" {
+		t.Errorf("expected output 3, got %q", out)
+	}
+	if r := c.request("continue", map[string]any{"threadId": 1}); r.Success {
+		t.Error("expected continue to fail once the program ended")
+	}
+	body(t, c.request("disconnect", nil))
+	c.close()
+}
+
+func TestStopOnEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fail.lox")
+	if err := os.WriteFile(path, []byte("print 1;\nprint nil + 1;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", map[string]any{"linesStartAt1": false})
+	body(t, c.request("configurationDone", nil))
+	body(t, c.request("launch", map[string]any{"program": path, "stopOnEntry": true}))
+	expect(t, "stop", c.wait("stopped"), `"reason":"entry"`)
+	expect(t, "stackTrace", body(t, c.request("stackTrace", map[string]any{"threadId": 1})), `"line":0,"column":0`)
+	body(t, c.request("continue", map[string]any{"threadId": 1}))
+	expect(t, "exited", c.wait("exited"), `"exitCode":70`)
+	expect(t, "stderr", c.output("stderr"), "Operands must be")
+	if r := c.request("launch", map[string]any{"program": path}); r.Success {
+		t.Error("expected a second launch to fail")
+	}
+	c.close()
+}
+
+func TestQuit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.lox")
+	if err := os.WriteFile(path, []byte("fun loop(n) { return loop(n + 1); }\nloop(0);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", map[string]any{})
+	body(t, c.request("launch", map[string]any{"program": path}))
+	body(t, c.request("configurationDone", nil))
+	body(t, c.request("pause", map[string]any{"threadId": 1}))
+	expect(t, "pause", c.wait("stopped"), `"reason":"pause"`)
+	body(t, c.request("disconnect", nil))
+	c.close()
+}
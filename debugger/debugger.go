@@ -0,0 +1,222 @@
+// Package debugger pauses a running Lox program at breakpoints and steps
+// through it. A front end, such as the command line of "lox debug", is
+// told whenever the program stops and decides how it goes on.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"lox/ast"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/scanner"
+	"sync"
+	"sync/atomic"
+)
+
+// Action is how the program goes on after a stop.
+type Action int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue Action = iota
+	// StepIn stops at the next statement on another line, in any
+	// function.
+	StepIn
+	// StepOver stops at the next statement on another line of the same
+	// function, or in its caller.
+	StepOver
+	// StepOut stops at the next statement in the caller.
+	StepOut
+	// Quit ends the program with ErrQuit.
+	Quit
+)
+
+// ErrQuit ends a program stopped with Quit.
+var ErrQuit = errors.New("debugging stopped")
+
+// Reasons given to Frontend.Stopped.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Frontend is told when the program stops. It may inspect the stopped
+// program through the Debugger while Stopped runs, and returns how to go
+// on.
+type Frontend interface {
+	Stopped(reason string) Action
+}
+
+// Frame is a function being run, innermost last in a stack.
+type Frame struct {
+	// Name is the name of the function, "<anonymous>" for one without
+	// one and "<script>" for the top level of the program.
+	Name string
+	File string
+	// Line is the line of the statement being run.
+	Line int
+	// Env is the innermost environment of the statement being run.
+	Env *interpreter.Environment
+}
+
+// Debugger is an interpreter.Observer that stops the program.
+type Debugger struct {
+	interpreter.NopObserver
+	interp   *interpreter.Interpreter
+	frontend Frontend
+	// breakpoints holds the lines to stop at, by file key. They may be
+	// changed while the program runs on another goroutine.
+	mu          sync.Mutex
+	breakpoints map[string]map[int]bool
+	frames      []*Frame
+
+	action Action
+	// depth and line are those of the last stop, which steps compare
+	// against.
+	depth int
+	line  int
+	// pause asks to stop at the next statement. It may be set while the
+	// program runs on another goroutine.
+	pause atomic.Bool
+}
+
+// New returns a debugger of the program run by interp, stopped before its
+// first statement. Attach it with interp.SetObserver.
+func New(interp *interpreter.Interpreter, frontend Frontend) *Debugger {
+	return &Debugger{
+		interp:      interp,
+		frontend:    frontend,
+		breakpoints: make(map[string]map[int]bool),
+		frames:      []*Frame{{Name: "<script>", File: interp.File()}},
+		action:      StepIn,
+		line:        -1,
+	}
+}
+
+// SetBreakpoint adds or removes a breakpoint on a line of file.
+func (d *Debugger) SetBreakpoint(file string, line int, on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = make(map[int]bool)
+	}
+	if on {
+		d.breakpoints[file][line] = true
+	} else {
+		delete(d.breakpoints[file], line)
+	}
+}
+
+// ClearBreakpoints removes every breakpoint of file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, file)
+}
+
+// Breakpoint reports whether line of file has a breakpoint.
+func (d *Debugger) Breakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[file][line]
+}
+
+// Pause stops the program at the next statement.
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Frames returns the call stack, innermost last. It is only meaningful
+// while the program is stopped.
+func (d *Debugger) Frames() []*Frame {
+	return d.frames
+}
+
+// Evaluate evaluates source, an expression, in the innermost environment
+// of frame. Calls it makes run to the end without stopping.
+func (d *Debugger) Evaluate(source string, frame int) (any, error) {
+	if frame < 0 || frame >= len(d.frames) {
+		return nil, fmt.Errorf("no frame %d", frame)
+	}
+	er.Errors = er.Errors[:0]
+	stmts := parser.NewParser(scanner.NewSacnner(source + ";").ScanTokens()).Parse()
+	if len(er.Errors) > 0 {
+		err := er.Errors[0]
+		er.Errors = er.Errors[:0]
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("not an expression: %s", source)
+	}
+	stmt, ok := stmts[0].(*ast.ExpressionStmt)
+	if !ok {
+		return nil, fmt.Errorf("not an expression: %s", source)
+	}
+	env := d.frames[frame].Env
+	if env == nil {
+		env = d.interp.Globals()
+	}
+	return d.interp.Evaluate(stmt.Expression, env)
+}
+
+func (d *Debugger) Statement(stmt ast.Stmt, env *interpreter.Environment) error {
+	frame := d.frames[len(d.frames)-1]
+	frame.Env, frame.File = env, d.interp.File()
+	line := ast.Line(stmt)
+	if _, ok := stmt.(*ast.BlockStmt); ok || line == 0 {
+		// A block stops at its statements instead.
+		return nil
+	}
+	previous := frame.Line
+	frame.Line = line
+	reason := d.stopReason(line, line != previous)
+	if reason == "" {
+		return nil
+	}
+	d.pause.Store(false)
+	d.action = d.frontend.Stopped(reason)
+	d.depth, d.line = len(d.frames), line
+	if d.action == Quit {
+		return ErrQuit
+	}
+	return nil
+}
+
+// stopReason returns why the program stops at a statement on line, or ""
+// if it goes on. newLine is whether the statement starts another line
+// than the one before it in the same frame.
+func (d *Debugger) stopReason(line int, newLine bool) string {
+	depth := len(d.frames)
+	moved := depth != d.depth || line != d.line
+	switch {
+	case d.pause.Load():
+		return ReasonPause
+	case d.action == StepIn && d.line < 0:
+		return ReasonEntry
+	case d.action == StepIn && moved:
+		return ReasonStep
+	case d.action == StepOver && (depth < d.depth || depth == d.depth && line != d.line):
+		return ReasonStep
+	case d.action == StepOut && depth < d.depth:
+		return ReasonStep
+	case newLine && d.Breakpoint(d.frames[depth-1].File, line):
+		return ReasonBreakpoint
+	}
+	return ""
+}
+
+func (d *Debugger) Call(fn *interpreter.Function, env *interpreter.Environment) {
+	name := fn.Name()
+	if name == "" {
+		name = "<anonymous>"
+	}
+	d.frames = append(d.frames, &Frame{Name: name, File: fn.File(), Env: env})
+}
+
+func (d *Debugger) Return(*interpreter.Function, any, error) {
+	d.frames = d.frames[:len(d.frames)-1]
+}
@@ -0,0 +1,142 @@
+package debugger
+
+import (
+	"fmt"
+	"io"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/scanner"
+	"strings"
+	"testing"
+)
+
+const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var total = add(1, 2);
+total = add(total, 3);
+print total;`
+
+// script is a front end that records each stop and goes on with the next
+// of its actions.
+type script struct {
+	debugger *Debugger
+	actions  []Action
+	// eval is evaluated at each stop.
+	eval  string
+	stops []string
+	// stopped is called at each stop when set.
+	stopped func(reason string)
+}
+
+func (s *script) Stopped(reason string) Action {
+	frames := s.debugger.Frames()
+	stop := fmt.Sprintf("%s %s:%d", reason, frames[len(frames)-1].Name, frames[len(frames)-1].Line)
+	if s.eval != "" {
+		value, err := s.debugger.Evaluate(s.eval, len(frames)-1)
+		if err != nil {
+			value = "error"
+		}
+		stop += fmt.Sprintf(" %s=%v", s.eval, value)
+	}
+	if s.stopped != nil {
+		s.stopped(reason)
+	}
+	s.stops = append(s.stops, stop)
+	action := Continue
+	if len(s.actions) > 0 {
+		action, s.actions = s.actions[0], s.actions[1:]
+	}
+	return action
+}
+
+func TestEvaluate(t *testing.T) {
+	interp := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	interp.SetOutput(io.Discard)
+	front := &script{actions: []Action{Continue}}
+	front.debugger = New(interp, front)
+	interp.SetObserver(front.debugger)
+	stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+	// Stop at the last statement, where total is set.
+	front.debugger.SetBreakpoint(interp.File(), 7, true)
+	var results []string
+	front.stopped = func(reason string) {
+		if reason != ReasonBreakpoint {
+			return
+		}
+		for _, expr := range []string{"total * 2", "total // a comment", "", "// only a comment", "1; 2", "var x = 1"} {
+			value, err := front.debugger.Evaluate(expr, 0)
+			if err != nil {
+				value = "error"
+			}
+			results = append(results, fmt.Sprintf("%s=%v", expr, value))
+		}
+	}
+	if _, err := interp.Run(stmts); err != nil {
+		t.Fatal(err)
+	}
+	expected := "total * 2=12\ntotal // a comment=error\n=error\n// only a comment=error\n1; 2=error\nvar x = 1=error"
+	if got := strings.Join(results, "\n"); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		breakpoints []int
+		actions     []Action
+		eval        string
+		stops       []string
+		output      string
+	}{
+		{
+			actions: []Action{StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, StepIn},
+			stops: []string{
+				"entry <script>:1", "step <script>:5", "step add:2", "step add:3",
+				"step <script>:6", "step add:2", "step add:3", "step <script>:7",
+			},
+			output: "6\n",
+		},
+		{
+			actions: []Action{StepOver, StepOver, StepOver},
+			stops:   []string{"entry <script>:1", "step <script>:5", "step <script>:6", "step <script>:7"},
+			output:  "6\n",
+		},
+		{
+			breakpoints: []int{3},
+			actions:     []Action{Continue, StepOut, Continue},
+			eval:        "sum",
+			stops:       []string{"entry <script>:1 sum=error", "breakpoint add:3 sum=3", "step <script>:6 sum=error", "breakpoint add:3 sum=6"},
+			output:      "6\n",
+		},
+		{
+			actions: []Action{StepIn, Quit},
+			stops:   []string{"entry <script>:1", "step <script>:5"},
+		},
+	}
+	for _, test := range tests {
+		er.Errors = er.Errors[:0]
+		stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+		interp := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+		var out strings.Builder
+		interp.SetOutput(&out)
+		front := &script{actions: test.actions, eval: test.eval}
+		front.debugger = New(interp, front)
+		for _, line := range test.breakpoints {
+			front.debugger.SetBreakpoint(interp.File(), line, true)
+		}
+		interp.SetObserver(front.debugger)
+		_, err := interp.Run(stmts)
+		if err != nil && err != ErrQuit {
+			t.Errorf("%v: %v", test.actions, err)
+		}
+		if strings.Join(front.stops, "\n") != strings.Join(test.stops, "\n") {
+			t.Errorf("%v: expected stops\n%s\ngot\n%s", test.actions, strings.Join(test.stops, "\n"), strings.Join(front.stops, "\n"))
+		}
+		if out.String() != test.output {
+			t.Errorf("%v: expected output %q, got %q", test.actions, test.output, out.String())
+		}
+	}
+}
@@ -7,13 +7,42 @@ import (
 
 var Errors []error = make([]error, 0, 10)
 
+// SyntaxError is a compile error. Token is where it was found, or nil for
+// errors of the scanner, which only know the line.
+type SyntaxError struct {
+	Line  int
+	Token *token.Token
+	Where string
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("[line %d] Error%s: %s", e.Line, e.Where, e.Msg)
+}
 func Report(line int, where, msg string) {
-	Errors = append(Errors, fmt.Errorf("[line %d] Error %s:%s", line, where, msg))
+	Errors = append(Errors, &SyntaxError{Line: line, Where: where, Msg: msg})
 }
 func Error(tok *token.Token, msg string) {
+	where := " at '" + tok.Lexeme + "'"
 	if tok.Typ == token.EOF {
-		Report(tok.Line, " at end", msg)
-	} else {
-		Report(tok.Line, " at '"+tok.Lexeme+"'", msg)
+		where = " at end"
 	}
+	Errors = append(Errors, &SyntaxError{Line: tok.Line, Token: tok, Where: where, Msg: msg})
+}
+
+// RuntimeError is raised while executing a program. It keeps the token
+// where evaluation failed so the error can be reported with its line.
+type RuntimeError struct {
+	Token token.Token
+	Msg   string
+}
+
+func NewRuntimeError(tok token.Token, msg string) *RuntimeError {
+	return &RuntimeError{
+		Token: tok,
+		Msg:   msg,
+	}
+}
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s\n[line %d]", e.Msg, e.Token.Line)
 }
@@ -0,0 +1,130 @@
+package interpreter
+
+import (
+	"fmt"
+	"lox/ast"
+	"lox/errors"
+	"lox/token"
+)
+
+// compoundOps maps each compound assignment token to the binary operator
+// it applies.
+var compoundOps = map[token.TokenType]token.TokenType{
+	token.PLUS_EQUAL:    token.PLUS,
+	token.MINUS_EQUAL:   token.MINUS,
+	token.STAR_EQUAL:    token.STAR,
+	token.SLASH_EQUAL:   token.SLASH,
+	token.PERCENT_EQUAL: token.PERCENT,
+	token.PLUS_PLUS:     token.PLUS,
+	token.MINUS_MINUS:   token.MINUS,
+}
+
+// reference is an assignable location. The sub-expressions of the target
+// are evaluated once when the reference is created, so reading and then
+// writing it does not repeat their side effects.
+type reference struct {
+	get func() (any, error)
+	set func(value any) (any, error)
+}
+
+func (i *Interpreter) reference(target ast.Expr) (*reference, error) {
+	switch target := target.(type) {
+	case *ast.VariableNode:
+		env := i.env
+		return &reference{
+			get: func() (any, error) { return env.get(target.Name) },
+			set: func(value any) (any, error) {
+				if _, err := env.assign(target.Name, value); err != nil {
+					return nil, err
+				}
+				i.assigned(target.Name, value, env)
+				return value, nil
+			},
+		}, nil
+	case *ast.GetNode:
+		object, err := i.eval(target.Object)
+		if err != nil {
+			return nil, err
+		}
+		return &reference{
+			get: func() (any, error) {
+				if m, ok := object.(*Map); ok {
+					if value, ok := m.Get(target.Name.Lexeme); ok {
+						return value, nil
+					}
+				}
+				return nil, errors.NewRuntimeError(target.Name, fmt.Sprintf("Undefined key '%s'.", target.Name.Lexeme))
+			},
+			set: func(value any) (any, error) { return setProperty(target.Name, object, value) },
+		}, nil
+	case *ast.IndexNode:
+		object, err := i.eval(target.Object)
+		if err != nil {
+			return nil, err
+		}
+		index, err := i.eval(target.Index)
+		if err != nil {
+			return nil, err
+		}
+		return &reference{
+			get: func() (any, error) { return indexGet(target.Bracket, object, index) },
+			set: func(value any) (any, error) { return indexSet(target.Bracket, object, index, value) },
+		}, nil
+	default:
+		return nil, fmt.Errorf("Invalid assignment target.")
+	}
+}
+func (i *Interpreter) evalCompoundAssign(expr *ast.CompoundAssignNode) (any, error) {
+	ref, err := i.reference(expr.Target)
+	if err != nil {
+		return nil, err
+	}
+	old, err := ref.get()
+	if err != nil {
+		return nil, err
+	}
+	value, err := i.eval(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	result, err := binaryOp(compoundOp(expr.Op), old, value)
+	if err != nil {
+		return nil, err
+	}
+	return ref.set(result)
+}
+func (i *Interpreter) evalUpdate(expr *ast.UpdateNode) (any, error) {
+	ref, err := i.reference(expr.Target)
+	if err != nil {
+		return nil, err
+	}
+	old, err := ref.get()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := old.(float64); !ok {
+		return nil, errors.NewRuntimeError(expr.Op, fmt.Sprintf("Operand of '%s' must be a number.", expr.Op.Lexeme))
+	}
+	result, err := binaryOp(compoundOp(expr.Op), old, 1.0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ref.set(result); err != nil {
+		return nil, err
+	}
+	if expr.Prefix {
+		return result, nil
+	}
+	return old, nil
+}
+
+// compoundOp builds the binary operator token applied by a compound
+// assignment or update, keeping the original position for errors.
+func compoundOp(op token.Token) token.Token {
+	typ := compoundOps[op.Typ]
+	return token.Token{
+		Typ:    typ,
+		Lexeme: op.Lexeme[:len(op.Lexeme)-1],
+		Line:   op.Line,
+	}
+}
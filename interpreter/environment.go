@@ -2,22 +2,54 @@ package interpreter
 
 import (
 	"fmt"
+	"lox/errors"
 	"lox/token"
+	"slices"
 )
 
 type Environment struct {
 	enclosing *Environment
 	values    map[string]any
+	// consts holds the names in values that were declared with const.
+	consts map[string]bool
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		values:    make(map[string]any),
+		consts:    make(map[string]bool),
 		enclosing: enclosing,
 	}
 }
+
+// Names returns the names defined directly in e, sorted.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Enclosing returns the environment e is nested in, or nil for the
+// globals.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Lookup returns the value of a name defined directly in e.
+func (e *Environment) Lookup(name string) (any, bool) {
+	value, ok := e.values[name]
+	return value, ok
+}
 func (e *Environment) define(name string, value any) {
 	e.values[name] = value
+	delete(e.consts, name)
+}
+func (e *Environment) defineConst(name string, value any) {
+	e.values[name] = value
+	e.consts[name] = true
 }
 func (e *Environment) get(name token.Token) (any, error) {
 	if value, ok := e.values[name.Lexeme]; ok {
@@ -26,15 +58,20 @@ func (e *Environment) get(name token.Token) (any, error) {
 	if e.enclosing != nil {
 		return e.enclosing.get(name)
 	}
-	return nil, fmt.Errorf("Undefined variable '%s'.", name.Lexeme)
+	return nil, errors.NewRuntimeError(name, fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
 }
 func (e *Environment) assign(name token.Token, value any) (any, error) {
-	if _, ok := e.values[name.Lexeme]; !ok {
-		return nil, fmt.Errorf("Undefined variable '%s'.", name.Lexeme)
+	if _, ok := e.values[name.Lexeme]; ok {
+		// The resolver rejects assignments it can see; this catches the
+		// rest, such as a const from an earlier REPL line.
+		if e.consts[name.Lexeme] {
+			return nil, errors.NewRuntimeError(name, fmt.Sprintf("Cannot assign to constant '%s'.", name.Lexeme))
+		}
+		e.values[name.Lexeme] = value
+		return value, nil
 	}
 	if e.enclosing != nil {
 		return e.enclosing.assign(name, value)
 	}
-	e.values[name.Lexeme] = value
-	return value, nil
+	return nil, errors.NewRuntimeError(name, fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
 }
@@ -0,0 +1,102 @@
+package interpreter
+
+import (
+	"errors"
+	"lox/ast"
+	"lox/token"
+)
+
+// Callable is implemented by every value that can appear as the callee of
+// a call expression.
+type Callable interface {
+	Arity() int
+	Call(i *Interpreter, args []any) (any, error)
+}
+
+// Function is a user defined function, either declared with a name or
+// created from an anonymous function expression. It closes over the
+// environment that was active when it was created.
+type Function struct {
+	name    string
+	params  []token.Token
+	body    []ast.Stmt
+	closure *Environment
+	// file is the key of the file that declares the function, "" if it is
+	// not known, and line the line of its name or fun keyword.
+	file string
+	line int
+}
+
+func NewFunction(name string, params []token.Token, body []ast.Stmt, closure *Environment) *Function {
+	return &Function{
+		name:    name,
+		params:  params,
+		body:    body,
+		closure: closure,
+	}
+}
+
+// newFunction creates a function declared on line, closing over the
+// current environment of the current file.
+func (i *Interpreter) newFunction(name string, line int, params []token.Token, body []ast.Stmt) *Function {
+	fn := NewFunction(name, params, body, i.env)
+	fn.file, fn.line = i.file, line
+	return fn
+}
+
+// Name returns the name of a declared function, or "" for an anonymous
+// one.
+func (f *Function) Name() string {
+	return f.name
+}
+
+// File returns the key of the file that declares f.
+func (f *Function) File() string {
+	return f.file
+}
+
+// Line returns the line where f is declared, or 0 if it is not known.
+func (f *Function) Line() int {
+	return f.line
+}
+func (f *Function) Arity() int {
+	return len(f.params)
+}
+func (f *Function) Call(i *Interpreter, args []any) (value any, err error) {
+	env := NewEnvironment(f.closure)
+	for idx, param := range f.params {
+		env.define(param.Lexeme, args[idx])
+	}
+	if observer := i.observer; observer != nil {
+		// The body runs in its own file, so that the observer knows where
+		// it is.
+		file := i.file
+		i.file = f.file
+		observer.Call(f, env)
+		defer func() {
+			observer.Return(f, value, err)
+			i.file = file
+		}()
+	}
+	_, err = i.evalBlock(f.body, env)
+	var ret *Return
+	if errors.As(err, &ret) {
+		return ret.Value, nil
+	}
+	return nil, err
+}
+func (f *Function) String() string {
+	if f.name == "" {
+		return "<fn anonymous>"
+	}
+	return "<fn " + f.name + ">"
+}
+
+// Return unwinds the statements of a function body back to Function.Call.
+type Return struct {
+	Value any
+}
+
+func (r *Return) Error() string {
+	return "return outside of a function"
+}
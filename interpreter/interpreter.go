@@ -1,22 +1,125 @@
 package interpreter
 
 import (
+	"cmp"
 	"fmt"
+	"io"
 	"lox/ast"
 	"lox/errors"
 	"lox/token"
-	"lox/util"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Interpreter struct {
-	env *Environment
+	env     *Environment
+	globals *Environment
+
+	// file is the loader key of the file being executed. Imports in it
+	// are resolved relative to this key.
+	file    string
+	loader  ModuleLoader
+	modules map[string]*Module
+	// natives are the modules implemented in Go, importable by name.
+	natives map[string]*Module
+	// loading is the chain of modules currently being imported, used to
+	// report import cycles.
+	loading []string
+
+	out  io.Writer
+	rand *rand.Rand
+	now  func() time.Time
+	caps Capability
+	args []string
+
+	observer Observer
+	// raised is the last runtime error reported to the observer.
+	raised *errors.RuntimeError
+	// depth is the number of calls in progress.
+	depth int
 }
 
+// maxCallDepth bounds the calls in progress, so that runaway recursion is
+// a runtime error rather than an overflow of the Go stack.
+const maxCallDepth = 10000
+
 func NewInterpreter(env *Environment) *Interpreter {
-	return &Interpreter{
-		env: env,
+	i := &Interpreter{
+		env:     env,
+		globals: env,
+		loader:  FileLoader{},
+		modules: make(map[string]*Module),
+		natives: make(map[string]*Module),
+		rand:    rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0)),
+		out:     os.Stdout,
+		now:     time.Now,
 	}
+	i.DefineNativeModule("math", mathModule())
+	i.DefineNativeModule("string", stringModule())
+	i.DefineNativeModule("json", jsonModule())
+	i.DefineNativeModule("re", reModule())
+	i.DefineNative("format", -1, func(_ *Interpreter, args []any) (any, error) {
+		return format(args)
+	})
+	i.defineOSNatives()
+	i.defineTimeNatives()
+	return i
+}
+
+// SetOutput redirects the output of print statements.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.out = w
+}
+
+// SetModuleLoader replaces the loader used to resolve imports and forgets
+// every module loaded so far.
+func (i *Interpreter) SetModuleLoader(loader ModuleLoader) {
+	i.loader = loader
+	i.modules = make(map[string]*Module)
+}
+
+// Loader returns the module loader. Tools use it to read the source of a
+// file by key.
+func (i *Interpreter) Loader() ModuleLoader {
+	return i.loader
+}
+
+// SetFile sets the path of the main script. Imports in it are resolved
+// relative to path by the module loader, so set the loader first. An empty
+// path means there is no script file.
+func (i *Interpreter) SetFile(path string) {
+	if path == "" {
+		i.file = ""
+		i.loading = nil
+		return
+	}
+	if key, err := i.loader.Resolve("", path); err == nil {
+		path = key
+	}
+	i.file = path
+	i.loading = []string{path}
+}
+
+// FileKey returns the key the module loader gives the file at path, as
+// used for the file of functions and by File.
+func (i *Interpreter) FileKey(path string) (string, error) {
+	return i.loader.Resolve("", path)
+}
+
+// File returns the key of the file being run: the main script set by
+// SetFile or a module it imports. While an observer is attached, functions
+// run in the file that declares them.
+func (i *Interpreter) File() string {
+	return i.file
+}
+
+// Globals returns the global environment.
+func (i *Interpreter) Globals() *Environment {
+	return i.globals
 }
 func (i *Interpreter) Run(stmts []ast.Stmt) (ret any, err error) {
 	for _, stmt := range stmts {
@@ -29,6 +132,12 @@ func (i *Interpreter) Run(stmts []ast.Stmt) (ret any, err error) {
 }
 
 func (i *Interpreter) evalStatement(stmt ast.Stmt) (any, error) {
+	if i.observer != nil {
+		return i.observeStatement(stmt)
+	}
+	return i.execute(stmt)
+}
+func (i *Interpreter) execute(stmt ast.Stmt) (any, error) {
 	switch stmt := stmt.(type) {
 	case *ast.ExpressionStmt:
 		return i.eval(stmt.Expression)
@@ -37,10 +146,7 @@ func (i *Interpreter) evalStatement(stmt ast.Stmt) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		if val == nil {
-			fmt.Printf("Error, <nil> pointer deref!\n")
-		}
-		fmt.Printf("%#v\n", val)
+		fmt.Fprintln(i.out, stringify(val))
 		return nil, nil
 	case *ast.VariableStmt:
 		var val any
@@ -51,7 +157,36 @@ func (i *Interpreter) evalStatement(stmt ast.Stmt) (any, error) {
 				return nil, err
 			}
 		}
-		i.env.define(stmt.Name.Lexeme, val)
+		if stmt.Kind == token.CONST {
+			i.env.defineConst(stmt.Name.Lexeme, val)
+		} else {
+			i.env.define(stmt.Name.Lexeme, val)
+		}
+		i.defined(stmt.Name, val)
+		return nil, nil
+	case *ast.FunctionStmt:
+		fn := i.newFunction(stmt.Name.Lexeme, stmt.Name.Line, stmt.Params, stmt.Body)
+		i.env.define(stmt.Name.Lexeme, fn)
+		i.defined(stmt.Name, fn)
+		return nil, nil
+	case *ast.ReturnStmt:
+		var val any
+		if stmt.Value != nil {
+			var err error
+			val, err = i.eval(stmt.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, &Return{Value: val}
+	case *ast.ImportStmt:
+		return nil, i.evalImport(stmt)
+	case *ast.ExportStmt:
+		return i.evalStatement(stmt.Decl)
+	case *ast.AssertStmt:
+		return nil, i.evalAssert(stmt)
+	case *ast.TestStmt:
+		// Tests only run through RunTest.
 		return nil, nil
 	case *ast.BlockStmt:
 		return i.evalBlock(stmt.Stmts, NewEnvironment(i.env))
@@ -60,7 +195,9 @@ func (i *Interpreter) evalStatement(stmt ast.Stmt) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		if isTruthy(cond) {
+		taken := isTruthy(cond)
+		i.branched(stmt, taken)
+		if taken {
 			return i.evalStatement(stmt.Then)
 		} else if stmt.Else != nil {
 			return i.evalStatement(stmt.Else)
@@ -70,6 +207,28 @@ func (i *Interpreter) evalStatement(stmt ast.Stmt) (any, error) {
 		return nil, nil
 	}
 }
+
+// RunTest runs the body of a test block in a scope of its own below the
+// globals, after the rest of the script has been run.
+func (i *Interpreter) RunTest(test *ast.TestStmt) error {
+	_, err := i.evalBlock(test.Body, NewEnvironment(i.globals))
+	return err
+}
+func (i *Interpreter) evalAssert(stmt *ast.AssertStmt) error {
+	cond, err := i.eval(stmt.Condition)
+	if err != nil || isTruthy(cond) {
+		return err
+	}
+	msg := "Assertion failed: " + ast.SourcePrinter(stmt.Condition)
+	if stmt.Message != nil {
+		message, err := i.eval(stmt.Message)
+		if err != nil {
+			return err
+		}
+		msg += ": " + stringify(message)
+	}
+	return errors.NewRuntimeError(stmt.Keyword, msg)
+}
 func (i *Interpreter) evalBlock(stmts []ast.Stmt, env *Environment) (ret any, err error) {
 	previous := i.env
 	defer func() {
@@ -85,6 +244,12 @@ func (i *Interpreter) evalBlock(stmts []ast.Stmt, env *Environment) (ret any, er
 	return
 }
 func (i *Interpreter) eval(expr ast.Expr) (any, error) {
+	if i.observer != nil {
+		return i.observeExpression(expr)
+	}
+	return i.evalExpr(expr)
+}
+func (i *Interpreter) evalExpr(expr ast.Expr) (any, error) {
 	switch expr := expr.(type) {
 	case *ast.LiteralNode:
 		return i.evalLiteral(expr), nil
@@ -103,11 +268,169 @@ func (i *Interpreter) eval(expr ast.Expr) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		v, _ := i.env.assign(expr.Name, val)
-		return v, nil
+		if _, err := i.env.assign(expr.Name, val); err != nil {
+			return nil, err
+		}
+		i.assigned(expr.Name, val, i.env)
+		return val, nil
+	case *ast.CompoundAssignNode:
+		return i.evalCompoundAssign(expr)
+	case *ast.UpdateNode:
+		return i.evalUpdate(expr)
+	case *ast.CallNode:
+		return i.evalCall(expr)
+	case *ast.GetNode:
+		return i.evalGet(expr)
+	case *ast.ListNode:
+		elements := make([]any, 0, len(expr.Elements))
+		for _, element := range expr.Elements {
+			val, err := i.eval(element)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, val)
+		}
+		return NewList(elements), nil
+	case *ast.MapNode:
+		return i.evalMap(expr)
+	case *ast.SetNode:
+		object, err := i.eval(expr.Object)
+		if err != nil {
+			return nil, err
+		}
+		val, err := i.eval(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		return setProperty(expr.Name, object, val)
+	case *ast.IndexNode:
+		object, err := i.eval(expr.Object)
+		if err != nil {
+			return nil, err
+		}
+		index, err := i.eval(expr.Index)
+		if err != nil {
+			return nil, err
+		}
+		return indexGet(expr.Bracket, object, index)
+	case *ast.SetIndexNode:
+		object, err := i.eval(expr.Object)
+		if err != nil {
+			return nil, err
+		}
+		index, err := i.eval(expr.Index)
+		if err != nil {
+			return nil, err
+		}
+		val, err := i.eval(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		return indexSet(expr.Bracket, object, index, val)
+	case *ast.LambdaNode:
+		return i.newFunction("", expr.Keyword.Line, expr.Params, expr.Body), nil
 	}
 	return nil, nil
 }
+func (i *Interpreter) evalCall(expr *ast.CallNode) (any, error) {
+	callee, err := i.eval(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, 0, len(expr.Args))
+	for _, arg := range expr.Args {
+		val, err := i.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+	fn, ok := callee.(Callable)
+	if !ok {
+		return nil, errors.NewRuntimeError(expr.Paren, "Can only call functions.")
+	}
+	if fn.Arity() >= 0 && len(args) != fn.Arity() {
+		return nil, errors.NewRuntimeError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", fn.Arity(), len(args)))
+	}
+	if i.depth >= maxCallDepth {
+		return nil, errors.NewRuntimeError(expr.Paren, "Stack overflow.")
+	}
+	i.depth++
+	ret, err := fn.Call(i, args)
+	i.depth--
+	if _, ok := fn.(*Native); ok && err != nil {
+		// Natives report plain errors; give them the position of the call
+		// unless they come from Lox code the native called back into.
+		switch err.(type) {
+		case *errors.RuntimeError, *Exit:
+		default:
+			err = errors.NewRuntimeError(expr.Paren, err.Error())
+		}
+	}
+	return ret, err
+}
+func (i *Interpreter) evalGet(expr *ast.GetNode) (any, error) {
+	object, err := i.eval(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	switch object := object.(type) {
+	case *Module:
+		if value, ok := object.get(expr.Name.Lexeme); ok {
+			return value, nil
+		}
+		return nil, errors.NewRuntimeError(expr.Name, fmt.Sprintf("Module %s has no export '%s'.", object.name, expr.Name.Lexeme))
+	case string:
+		if method, ok := stringMethod(object, expr.Name.Lexeme); ok {
+			return method, nil
+		}
+	case *List:
+		if method, ok := listMethod(object, expr.Name.Lexeme); ok {
+			return method, nil
+		}
+	case *Regex:
+		if value, ok := regexMethod(object, expr.Name.Lexeme); ok {
+			return value, nil
+		}
+	case *Map:
+		if value, ok := object.Get(expr.Name.Lexeme); ok {
+			return value, nil
+		}
+		if method, ok := mapMethod(object, expr.Name.Lexeme); ok {
+			return method, nil
+		}
+		return nil, errors.NewRuntimeError(expr.Name, fmt.Sprintf("Undefined key '%s'.", expr.Name.Lexeme))
+	default:
+		return nil, errors.NewRuntimeError(expr.Name, "Only modules, maps, strings, lists and regexes have properties.")
+	}
+	return nil, errors.NewRuntimeError(expr.Name, fmt.Sprintf("Undefined method '%s'.", expr.Name.Lexeme))
+}
+func (i *Interpreter) evalMap(expr *ast.MapNode) (any, error) {
+	m := NewMap()
+	for idx := range expr.Keys {
+		key, err := i.eval(expr.Keys[idx])
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKey(key); err != nil {
+			return nil, errors.NewRuntimeError(expr.Brace, err.Error())
+		}
+		val, err := i.eval(expr.Values[idx])
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, val)
+	}
+	return m, nil
+}
+func setProperty(name token.Token, object, value any) (any, error) {
+	m, ok := object.(*Map)
+	if !ok {
+		return nil, errors.NewRuntimeError(name, "Only maps have settable properties.")
+	}
+	m.Set(name.Lexeme, value)
+	return value, nil
+}
 
 func (i *Interpreter) evalLiteral(expr *ast.LiteralNode) any {
 	return expr.Value
@@ -121,10 +444,17 @@ func (i *Interpreter) evalUnary(expr *ast.UnaryNode) (any, error) {
 	case token.BANG:
 		return !isTruthy(right), nil
 	case token.MINUS:
-		if ok := checkOp(expr.Op, right); !ok {
-			return nil, fmt.Errorf("%+v Operand must be a number", expr.Op.Lexeme)
+		r, ok := right.(float64)
+		if !ok {
+			return nil, errors.NewRuntimeError(expr.Op, "Operand must be a number.")
+		}
+		return -r, nil
+	case token.TILDE:
+		r, err := integerOperand(expr.Op, right)
+		if err != nil {
+			return nil, err
 		}
-		return -right.(float64), nil
+		return float64(^r), nil
 	}
 	return nil, nil
 }
@@ -137,100 +467,143 @@ func (i *Interpreter) evalBinary(expr *ast.BinaryNode) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch expr.Op.Typ {
-	case token.MINUS:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers or strings", expr.Op)
-		}
-		return left.(float64) - right.(float64), nil
+	return binaryOp(expr.Op, left, right)
+}
+func binaryOp(op token.Token, left, right any) (any, error) {
+	switch op.Typ {
 	case token.PLUS:
-		switch left.(type) {
+		switch l := left.(type) {
 		case string:
-			if right, ok := right.(float64); ok {
-				return fmt.Sprintf("%s%+v", left, right), nil
-			}
-			if _, ok := right.(string); ok {
-				return left.(string) + right.(string), nil
+			switch r := right.(type) {
+			case string:
+				return l + r, nil
+			case float64:
+				return l + stringify(r), nil
 			}
-			errors.Error(&expr.Op, fmt.Sprintf("%+v Operands must be  numbers or strings.", expr.Op))
-			return nil, fmt.Errorf("%+v Operands must be  numbers or strings.", expr.Op)
-
 		case float64:
-			if right, ok := right.(string); ok {
-				return fmt.Sprintf("%+v%s", left, right), nil
+			switch r := right.(type) {
+			case float64:
+				return l + r, nil
+			case string:
+				return stringify(l) + r, nil
 			}
-			if _, ok := right.(float64); ok {
-
-				return left.(float64) + right.(float64), nil
-			}
-			errors.Error(&expr.Op, fmt.Sprintf("%+v Operands must be  numbers or  strings.", expr.Op))
-		}
-		errors.Error(&expr.Op, fmt.Sprintf("%+v Operands must be  numbers or  strings.", expr.Op))
-		return nil, fmt.Errorf("%+v Operands must be  numbers or  strings.", expr.Op)
-	case token.SLASH:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers", expr.Op)
-		}
-		return left.(float64) / right.(float64), nil
-	case token.STAR:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers", expr.Op)
-		}
-		return left.(float64) * right.(float64), nil
-	case token.GREATER:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers or strings", expr.Op)
-		}
-		if left, ok := left.(string); ok {
-			return strings.Compare(left, right.(string)) > 0, nil
-		}
-		return left.(float64) > right.(float64), nil
-	case token.GREATER_EQUAL:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers or strings", expr.Op)
 		}
-		if left, ok := left.(string); ok {
-			return strings.Compare(left, right.(string)) >= 0, nil
-		}
-		return left.(float64) >= right.(float64), nil
-	case token.LESS:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers or strings", expr.Op)
-		}
-		if left, ok := left.(string); ok {
-			return strings.Compare(left, right.(string)) < 0, nil
+		return nil, errors.NewRuntimeError(op, "Operands must be numbers or strings.")
+	case token.MINUS, token.STAR, token.SLASH, token.PERCENT, token.TILDE_SLASH, token.STAR_STAR:
+		l, r, err := numberOperands(op, left, right)
+		if err != nil {
+			return nil, err
 		}
-		return left.(float64) < right.(float64), nil
-	case token.LESS_EQUAL:
-		if ok := checkOps(expr.Op, left, right); !ok {
-			return nil, fmt.Errorf("%+v Operands must be numbers or strings", expr.Op)
+		return arithmetic(op, l, r)
+	case token.AMPERSAND, token.PIPE, token.CARET, token.LESS_LESS, token.GREATER_GREATER:
+		l, err := integerOperand(op, left)
+		if err != nil {
+			return nil, err
 		}
-		if left, ok := left.(string); ok {
-			return strings.Compare(left, right.(string)) <= 0, nil
+		r, err := integerOperand(op, right)
+		if err != nil {
+			return nil, err
 		}
-		return left.(float64) <= right.(float64), nil
+		return bitwise(op, l, r)
+	case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		return compare(op, left, right)
 	case token.EQUAL_EQUAL:
 		return isEqual(left, right), nil
 	case token.BANG_EQUAL:
 		return !isEqual(left, right), nil
 	default:
-		return nil, fmt.Errorf("not supported operator %#v", expr.Op.Lexeme)
+		return nil, errors.NewRuntimeError(op, fmt.Sprintf("Unsupported operator '%s'.", op.Lexeme))
+	}
+}
+func arithmetic(op token.Token, l, r float64) (any, error) {
+	switch op.Typ {
+	case token.MINUS:
+		return l - r, nil
+	case token.STAR:
+		return l * r, nil
+	case token.STAR_STAR:
+		return math.Pow(l, r), nil
+	}
+	if r == 0 {
+		return nil, errors.NewRuntimeError(op, "Division by zero.")
+	}
+	switch op.Typ {
+	case token.SLASH:
+		return l / r, nil
+	case token.PERCENT:
+		// '%' takes the sign of the divisor like Python's, so that
+		// (a ~/ b) * b + a % b == a.
+		m := math.Mod(l, r)
+		if m != 0 && (m < 0) != (r < 0) {
+			m += r
+		}
+		return m, nil
+	default:
+		// '~/' floors like Python's '//', which Lox cannot spell because
+		// '//' already starts a line comment.
+		return math.Floor(l / r), nil
+	}
+}
+func bitwise(op token.Token, l, r int64) (any, error) {
+	switch op.Typ {
+	case token.AMPERSAND:
+		return float64(l & r), nil
+	case token.PIPE:
+		return float64(l | r), nil
+	case token.CARET:
+		return float64(l ^ r), nil
+	}
+	if r < 0 {
+		return nil, errors.NewRuntimeError(op, "Shift count must not be negative.")
+	}
+	if op.Typ == token.LESS_LESS {
+		return float64(l << r), nil
+	}
+	return float64(l >> r), nil
+}
+func compare(op token.Token, left, right any) (any, error) {
+	var c int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, errors.NewRuntimeError(op, "Operands must be two numbers or two strings.")
+		}
+		c = cmp.Compare(l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, errors.NewRuntimeError(op, "Operands must be two numbers or two strings.")
+		}
+		c = strings.Compare(l, r)
+	default:
+		return nil, errors.NewRuntimeError(op, "Operands must be two numbers or two strings.")
+	}
+	switch op.Typ {
+	case token.GREATER:
+		return c > 0, nil
+	case token.GREATER_EQUAL:
+		return c >= 0, nil
+	case token.LESS:
+		return c < 0, nil
+	default:
+		return c <= 0, nil
 	}
 }
+
+// evalCondition evaluates only the branch chosen by the truthiness of the
+// condition, as an if statement does.
 func (i *Interpreter) evalCondition(expr *ast.ConditionNode) (any, error) {
 	cond, err := i.eval(expr.Condition)
 	if err != nil {
 		return nil, err
 	}
-	t, err := i.eval(expr.Truth)
-	if err != nil {
-		return nil, err
-	}
-	f, err := i.eval(expr.False)
-	if err != nil {
-		return nil, err
+	taken := isTruthy(cond)
+	i.branched(expr, taken)
+	if taken {
+		return i.eval(expr.Truth)
 	}
-	return util.When(cond.(bool), t, f), nil
+	return i.eval(expr.False)
 }
 func isEqual(left, right any) bool {
 	if left == nil && right == nil {
@@ -241,46 +614,50 @@ func isEqual(left, right any) bool {
 	}
 	switch left := left.(type) {
 	case string:
-		return strings.EqualFold(left, right.(string))
+		right, ok := right.(string)
+		return ok && strings.EqualFold(left, right)
 	case float64:
-		return left == right.(float64)
+		right, ok := right.(float64)
+		return ok && left == right
 	case bool:
-		return left == right.(bool)
+		right, ok := right.(bool)
+		return ok && left == right
 	default:
-		return false
+		return left == right
 	}
 }
-func checkOp(tok token.Token, operand any) bool {
-	if _, ok := operand.(float64); ok {
-		return true
+func numberOperands(op token.Token, left, right any) (float64, float64, error) {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return 0, 0, errors.NewRuntimeError(op, "Operands must be numbers.")
 	}
-	errors.Error(&tok, fmt.Sprintf("%+v Operand must be a number", tok.Lexeme))
-	return false
+	return l, r, nil
 }
-func checkOps(tok token.Token, left, right any) bool {
 
-	if _, ok := left.(float64); ok {
-		if right, ok := right.(float64); !ok {
-			errors.Error(&tok, fmt.Sprintf("%+v %+v %+v , Operands must be two numbers or two strings", left, tok.Lexeme, right))
-			return false
-		} else if right == 0 {
-			errors.Error(&tok, fmt.Sprintf("%+v %+v %+v , Devide zero!", left, tok.Lexeme, right))
-			return false
-		}
-	} else if _, ok = left.(string); ok {
-		if tok.Typ == token.STAR || tok.Typ == token.SLASH || tok.Typ == token.MINUS {
-			errors.Error(&tok, fmt.Sprintf("%+v %+v %+v , Operands must be two numbers ", left, tok.Lexeme, right))
-			return false
-		}
-		if right, ok := right.(string); !ok {
-			errors.Error(&tok, fmt.Sprintf("%+v %+v %+v , Operands must be two numbers or two strings", left, tok.Lexeme, right))
-			return false
-		}
-	} else {
-		errors.Error(&tok, fmt.Sprintf("%+v %+v %+v , Operands must be  two numbers or  two strings", left, tok.Lexeme, right))
-		return false
+// integerOperand converts a number with no fractional part to int64 for
+// the bitwise operators.
+func integerOperand(op token.Token, operand any) (int64, error) {
+	v, ok := operand.(float64)
+	if !ok || v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+		return 0, errors.NewRuntimeError(op, "Operands must be integers.")
+	}
+	return int64(v), nil
+}
+
+func stringify(val any) string {
+	switch val := val.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprint(val)
 	}
-	return true
 }
 func isTruthy(val any) bool {
 	if val == nil {
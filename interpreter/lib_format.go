@@ -0,0 +1,147 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// format implements the format builtin. Each directive is
+// %[flags][width][.precision]verb with flags from "-+ 0#" and one of the
+// verbs s, d, f, x and v, or %% for a literal percent sign. %s and %v
+// render values exactly as print does.
+func format(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("format: expected a format string.")
+	}
+	layout, err := stringArg("format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	args = args[1:]
+	var sb strings.Builder
+	next := 0
+	for idx := 0; idx < len(layout); idx++ {
+		ch := layout[idx]
+		if ch != '%' {
+			sb.WriteByte(ch)
+			continue
+		}
+		start := idx
+		idx++
+		for idx < len(layout) && strings.IndexByte("-+ 0#", layout[idx]) >= 0 {
+			idx++
+		}
+		digits := idx
+		for idx < len(layout) && isDigit(layout[idx]) {
+			idx++
+		}
+		if err := checkFormatNumber("width", layout[digits:idx]); err != nil {
+			return nil, err
+		}
+		if idx < len(layout) && layout[idx] == '.' {
+			idx++
+			digits = idx
+			for idx < len(layout) && isDigit(layout[idx]) {
+				idx++
+			}
+			if err := checkFormatNumber("precision", layout[digits:idx]); err != nil {
+				return nil, err
+			}
+		}
+		if idx >= len(layout) {
+			return nil, fmt.Errorf("format: incomplete directive '%s' at end of format.", layout[start:])
+		}
+		spec, verb := layout[start:idx], layout[idx]
+		if verb == '%' {
+			if idx != start+1 {
+				return nil, fmt.Errorf("format: '%%%%' does not take flags, width or precision.")
+			}
+			sb.WriteByte('%')
+			continue
+		}
+		if next >= len(args) {
+			return nil, fmt.Errorf("format: missing argument for '%s%c'.", spec, verb)
+		}
+		arg := args[next]
+		next++
+		value, err := formatValue(verb, arg)
+		if err != nil {
+			return nil, fmt.Errorf("format: '%s%c' %s", spec, verb, err)
+		}
+		fmt.Fprintf(&sb, spec+string(verb), value)
+	}
+	if next < len(args) {
+		return nil, fmt.Errorf("format: %d arguments given but only %d used.", len(args), next)
+	}
+	return sb.String(), nil
+}
+
+// maxFormatNumber is the largest width or precision a directive may ask
+// for.
+const maxFormatNumber = 1000
+
+// checkFormatNumber rejects a width or precision, given as its digits,
+// above maxFormatNumber.
+func checkFormatNumber(what, digits string) error {
+	if digits == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(digits); err != nil || n > maxFormatNumber {
+		return fmt.Errorf("format: %s %s is larger than %d.", what, digits, maxFormatNumber)
+	}
+	return nil
+}
+
+// formatValue converts a Lox value into the Go value that fmt expects for
+// the verb, rejecting values of the wrong type.
+func formatValue(verb byte, arg any) (any, error) {
+	switch verb {
+	case 's', 'v':
+		return stringify(arg), nil
+	case 'f':
+		if f, ok := arg.(float64); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("expects a number, got %s %s.", TypeName(arg), repr(arg, nil))
+	case 'd', 'x':
+		if f, ok := arg.(float64); ok && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+		if s, ok := arg.(string); ok && verb == 'x' {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expects an integer, got %s %s.", TypeName(arg), repr(arg, nil))
+	default:
+		return nil, fmt.Errorf("is not a supported verb.")
+	}
+}
+
+// TypeName names the Lox type of a value for error messages and debuggers.
+func TypeName(val any) string {
+	switch val.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *Regex:
+		return "regex"
+	case *Module:
+		return "module"
+	case Callable:
+		return "function"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,56 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		args []any
+		want string
+	}{
+		{[]any{"%-6s|%6.2f|", "tea", 2.5}, "tea   |  2.50|"},
+		{[]any{"%05d|%x", 42.0, 255.0}, "00042|ff"},
+		{[]any{"%+d % d", 7.0, 7.0}, "+7  7"},
+		{[]any{"%v %s %s", NewList([]any{1.0, "a"}), nil, 3.0}, `[1, "a"] nil 3`},
+		{[]any{"%.3s|%%", "abcdef"}, "abc|%"},
+		{[]any{"%x", "hi"}, "6869"},
+		{[]any{"%1000.1000f|", 1.0}, "1." + strings.Repeat("0", 1000) + "|"},
+	}
+	for _, test := range tests {
+		got, err := format(test.args)
+		if err != nil || got != test.want {
+			t.Errorf("%q: expected %q, got %q (%v)", test.args[0], test.want, got, err)
+		}
+	}
+
+	errs := [][]any{
+		{"%d", 1.5},
+		{"%f", "x"},
+		{"%d %d", 1.0},
+		{"%s", 1.0, 2.0},
+		{"%5"},
+		{"%5%"},
+		{"%X", 255.0},
+	}
+	for _, args := range errs {
+		if got, err := format(args); err == nil {
+			t.Errorf("%q: expected an error, got %q", args[0], got)
+		}
+	}
+
+	limits := []struct {
+		layout string
+		err    string
+	}{
+		{"%9999999999999d", "format: width 9999999999999 is larger than 1000."},
+		{"%1001s", "format: width 1001 is larger than 1000."},
+		{"%.99999999999999999999f", "format: precision 99999999999999999999 is larger than 1000."},
+	}
+	for _, test := range limits {
+		if _, err := format([]any{test.layout, 1.0}); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error %q, got %v", test.layout, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package interpreter
+
+import (
+	"fmt"
+	"lox/util"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func jsonModule() *Module {
+	return NewNativeModule("json", map[string]any{
+		"parse": NewNative("json.parse", 1, func(_ *Interpreter, args []any) (any, error) {
+			text, err := stringArg("json.parse", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return parseJSON(text)
+		}),
+		"stringify": NewNative("json.stringify", -1, func(_ *Interpreter, args []any) (any, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("json.stringify: expected 1 or 2 arguments but got %d.", len(args))
+			}
+			indent := ""
+			if len(args) == 2 && args[1] != nil {
+				switch v := args[1].(type) {
+				case float64:
+					n, err := integerArg("json.stringify", args, 1)
+					if err != nil || n < 0 {
+						return nil, fmt.Errorf("json.stringify: indent must be a non-negative integer or a string.")
+					}
+					indent = strings.Repeat(" ", n)
+				case string:
+					indent = v
+				default:
+					return nil, fmt.Errorf("json.stringify: indent must be a non-negative integer or a string.")
+				}
+			}
+			var sb strings.Builder
+			if err := writeJSON(&sb, args[0], indent, "", nil); err != nil {
+				return nil, fmt.Errorf("json.stringify: %s", err)
+			}
+			return sb.String(), nil
+		}),
+	})
+}
+
+// writeJSON serializes val. Maps keep their insertion order; seen holds
+// the collections being written so that cycles and values nested deeper
+// than maxNesting are reported instead of overflowing the stack.
+func writeJSON(sb *strings.Builder, val any, indent, prefix string, seen []any) error {
+	switch val.(type) {
+	case *List, *Map:
+		if len(seen) >= maxNesting {
+			return fmt.Errorf("cannot stringify a structure nested more than %d levels deep.", maxNesting)
+		}
+	}
+	switch val := val.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(val))
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Errorf("cannot represent %s in JSON.", stringify(val))
+		}
+		sb.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+	case string:
+		writeJSONString(sb, val)
+	case *List:
+		if slices.Contains(seen, any(val)) {
+			return fmt.Errorf("cannot stringify a cyclic structure.")
+		}
+		seen = append(seen, val)
+		if len(val.elements) == 0 {
+			sb.WriteString("[]")
+			return nil
+		}
+		sb.WriteString("[")
+		inner := prefix + indent
+		for idx, element := range val.elements {
+			if idx > 0 {
+				sb.WriteString(",")
+			}
+			newline(sb, indent, inner)
+			if err := writeJSON(sb, element, indent, inner, seen); err != nil {
+				return err
+			}
+		}
+		newline(sb, indent, prefix)
+		sb.WriteString("]")
+	case *Map:
+		if slices.Contains(seen, any(val)) {
+			return fmt.Errorf("cannot stringify a cyclic structure.")
+		}
+		seen = append(seen, val)
+		if val.Len() == 0 {
+			sb.WriteString("{}")
+			return nil
+		}
+		sb.WriteString("{")
+		inner := prefix + indent
+		for idx, key := range val.keys {
+			name, ok := key.(string)
+			if !ok {
+				return fmt.Errorf("map key %s is not a string.", repr(key, nil))
+			}
+			if idx > 0 {
+				sb.WriteString(",")
+			}
+			newline(sb, indent, inner)
+			writeJSONString(sb, name)
+			sb.WriteString(util.When(indent == "", ":", ": "))
+			if err := writeJSON(sb, val.values[key], indent, inner, seen); err != nil {
+				return err
+			}
+		}
+		newline(sb, indent, prefix)
+		sb.WriteString("}")
+	default:
+		return fmt.Errorf("cannot stringify a %s.", TypeName(val))
+	}
+	return nil
+}
+func newline(sb *strings.Builder, indent, prefix string) {
+	if indent != "" {
+		sb.WriteString("\n")
+		sb.WriteString(prefix)
+	}
+}
+func writeJSONString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// jsonParser is a recursive descent parser for RFC 8259 JSON that tracks
+// the line and column of the input for error messages.
+type jsonParser struct {
+	text string
+	pos  int
+	// depth is the number of arrays and objects being parsed.
+	depth int
+}
+
+func parseJSON(text string) (any, error) {
+	p := &jsonParser{text: text}
+	p.skipSpace()
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return nil, p.errorf("unexpected %s after JSON value", p.describe())
+	}
+	return val, nil
+}
+func (p *jsonParser) errorf(format string, args ...any) error {
+	line, col := 1, 1
+	for _, r := range p.text[:p.pos] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("json.parse: %s at line %d, column %d.", fmt.Sprintf(format, args...), line, col)
+}
+
+// describe names the input at the current position for error messages.
+func (p *jsonParser) describe() string {
+	if p.pos >= len(p.text) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(p.text[p.pos:])
+	return strconv.QuoteRune(r)
+}
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+func (p *jsonParser) value() (any, error) {
+	if p.pos >= len(p.text) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch ch := p.text[p.pos]; {
+	case ch == '{' || ch == '[':
+		if p.depth >= maxNesting {
+			return nil, p.errorf("nesting deeper than %d levels", maxNesting)
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		if ch == '{' {
+			return p.object()
+		}
+		return p.array()
+	case ch == '"':
+		return p.string()
+	case ch == '-' || isDigit(ch):
+		return p.number()
+	case strings.HasPrefix(p.text[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.text[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	case strings.HasPrefix(p.text[p.pos:], "null"):
+		p.pos += 4
+		return nil, nil
+	default:
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+}
+func (p *jsonParser) object() (any, error) {
+	p.pos++
+	m := NewMap()
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == '}' {
+		p.pos++
+		return m, nil
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != '"' {
+			return nil, p.errorf("expected string key but found %s", p.describe())
+		}
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+			return nil, p.errorf("expected ':' but found %s", p.describe())
+		}
+		p.pos++
+		p.skipSpace()
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, val)
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.text) && p.text[p.pos] == '}' {
+			p.pos++
+			return m, nil
+		}
+		return nil, p.errorf("expected ',' or '}' but found %s", p.describe())
+	}
+}
+func (p *jsonParser) array() (any, error) {
+	p.pos++
+	elements := make([]any, 0, 4)
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == ']' {
+		p.pos++
+		return NewList(elements), nil
+	}
+	for {
+		p.skipSpace()
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, val)
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.text) && p.text[p.pos] == ']' {
+			p.pos++
+			return NewList(elements), nil
+		}
+		return nil, p.errorf("expected ',' or ']' but found %s", p.describe())
+	}
+}
+func (p *jsonParser) number() (any, error) {
+	start := p.pos
+	if p.text[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() int {
+		n := 0
+		for p.pos < len(p.text) && isDigit(p.text[p.pos]) {
+			p.pos++
+			n++
+		}
+		return n
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '0' {
+		p.pos++
+	} else if digits() == 0 {
+		return nil, p.errorf("invalid number")
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '.' {
+		p.pos++
+		if digits() == 0 {
+			return nil, p.errorf("expected digit after decimal point")
+		}
+	}
+	if p.pos < len(p.text) && (p.text[p.pos] == 'e' || p.text[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.text) && (p.text[p.pos] == '+' || p.text[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			return nil, p.errorf("expected digit in exponent")
+		}
+	}
+	f, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("number out of range")
+	}
+	return f, nil
+}
+func (p *jsonParser) string() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.text) {
+			return "", p.errorf("unterminated string")
+		}
+		ch := p.text[p.pos]
+		switch {
+		case ch == '"':
+			p.pos++
+			return sb.String(), nil
+		case ch < 0x20:
+			return "", p.errorf("control character in string")
+		case ch != '\\':
+			r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		p.pos++
+		if p.pos >= len(p.text) {
+			return "", p.errorf("unterminated string")
+		}
+		esc := p.text[p.pos]
+		p.pos++
+		switch esc {
+		case '"', '\\', '/':
+			sb.WriteByte(esc)
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			r, err := p.hex4()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) && strings.HasPrefix(p.text[p.pos:], `\u`) {
+				p.pos += 2
+				low, err := p.hex4()
+				if err != nil {
+					return "", err
+				}
+				r = utf16.DecodeRune(r, low)
+			}
+			sb.WriteRune(r)
+		default:
+			p.pos -= 2
+			return "", p.errorf("invalid escape sequence")
+		}
+	}
+}
+func (p *jsonParser) hex4() (rune, error) {
+	if p.pos+4 > len(p.text) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	n, err := strconv.ParseUint(p.text[p.pos:p.pos+4], 16, 16)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(n), nil
+}
@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{"b": 1, "a": [true, null, -2.5e1]}`, `{"b": 1, "a": [true, nil, -25]}`},
+		{`"tab\tquote\" é 😀"`, "tab\tquote\" é 😀"},
+		{` [ ] `, `[]`},
+		{`{}`, `{}`},
+	}
+	for _, test := range tests {
+		got, err := parseJSON(test.text)
+		if err != nil || stringify(got) != test.want {
+			t.Errorf("%s: expected %s, got %v (%v)", test.text, test.want, stringify(got), err)
+		}
+	}
+
+	errs := []struct {
+		text string
+		want string
+	}{
+		{`{"a": 1,}`, "expected string key but found '}' at line 1, column 9."},
+		{"[1,\n  2\n  x]", "expected ',' or ']' but found 'x' at line 3, column 3."},
+		{`{"a" 1}`, "expected ':' but found '1' at line 1, column 6."},
+		{`"open`, "unterminated string at line 1, column 6."},
+		{`01`, "unexpected '1' after JSON value at line 1, column 2."},
+		{``, "unexpected end of input at line 1, column 1."},
+		{strings.Repeat("[", 50000000), "nesting deeper than 10000 levels at line 1, column 10001."},
+		{strings.Repeat(`{"a":`, 20000), "nesting deeper than 10000 levels at line 1, column 50001."},
+	}
+	for _, test := range errs {
+		_, err := parseJSON(test.text)
+		if err == nil || !strings.HasSuffix(err.Error(), test.want) {
+			t.Errorf("%q: expected error %q, got %v", test.text, test.want, err)
+		}
+	}
+}
+func TestStringifyJSON(t *testing.T) {
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`json.stringify({"a": [1, "x", nil, true]});`, `{"a":[1,"x",null,true]}`},
+		{`json.stringify([1, {"k": 2}], 1);`, "[\n 1,\n {\n  \"k\": 2\n }\n]"},
+		{`json.stringify({"a": 1}, "--");`, "{\n--\"a\": 1\n}"},
+		{`json.parse(json.stringify({"x": [1, 2]})).x[1];`, 2.0},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %q, got %q (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	errs := []string{
+		`json.stringify(fun () {});`,
+		`json.stringify({1: 2});`,
+		`var xs = []; xs.push(xs); json.stringify(xs);`,
+		`json.stringify(math.NAN);`,
+	}
+	for _, source := range errs {
+		if _, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), source); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+
+	// Deeply nested values fail to stringify and print with the innermost
+	// levels elided.
+	deep := NewList(nil)
+	for range 2 * maxNesting {
+		deep = NewList([]any{deep})
+	}
+	var sb strings.Builder
+	if err := writeJSON(&sb, deep, "", "", nil); err == nil || !strings.Contains(err.Error(), "nested more than 10000 levels") {
+		t.Errorf("expected a nesting error, got %v", err)
+	}
+	if got, want := Repr(deep), strings.Repeat("[", maxNesting)+"[...]"+strings.Repeat("]", maxNesting); got != want {
+		t.Errorf("expected the nesting to be elided past %d levels, got %.40q", maxNesting, got)
+	}
+}
@@ -0,0 +1,126 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// SetRandomSeed makes math.random and math.randomInt produce a fixed
+// sequence, so that scripts using them can be tested.
+func (i *Interpreter) SetRandomSeed(seed uint64) {
+	i.rand = rand.New(rand.NewPCG(seed, 0))
+}
+func mathModule() *Module {
+	members := map[string]any{
+		"PI":  math.Pi,
+		"E":   math.E,
+		"INF": math.Inf(1),
+		"NAN": math.NaN(),
+	}
+	unary := map[string]func(float64) float64{
+		"sqrt":  math.Sqrt,
+		"abs":   math.Abs,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"asin":  math.Asin,
+		"acos":  math.Acos,
+		"atan":  math.Atan,
+		"log":   math.Log,
+		"log2":  math.Log2,
+		"log10": math.Log10,
+		"exp":   math.Exp,
+	}
+	for name, fn := range unary {
+		qualified := "math." + name
+		members[name] = NewNative(qualified, 1, func(_ *Interpreter, args []any) (any, error) {
+			x, err := numberArg(qualified, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fn(x), nil
+		})
+	}
+	binary := map[string]func(float64, float64) float64{
+		"pow":   math.Pow,
+		"atan2": math.Atan2,
+	}
+	for name, fn := range binary {
+		qualified := "math." + name
+		members[name] = NewNative(qualified, 2, func(_ *Interpreter, args []any) (any, error) {
+			x, err := numberArg(qualified, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			y, err := numberArg(qualified, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return fn(x, y), nil
+		})
+	}
+	members["min"] = NewNative("math.min", -1, func(_ *Interpreter, args []any) (any, error) {
+		return fold("math.min", args, math.Min)
+	})
+	members["max"] = NewNative("math.max", -1, func(_ *Interpreter, args []any) (any, error) {
+		return fold("math.max", args, math.Max)
+	})
+	members["isNaN"] = NewNative("math.isNaN", 1, func(_ *Interpreter, args []any) (any, error) {
+		x, ok := args[0].(float64)
+		return ok && math.IsNaN(x), nil
+	})
+	members["random"] = NewNative("math.random", 0, func(i *Interpreter, _ []any) (any, error) {
+		return i.rand.Float64(), nil
+	})
+	members["randomInt"] = NewNative("math.randomInt", 2, func(i *Interpreter, args []any) (any, error) {
+		lo, err := integerArg("math.randomInt", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := integerArg("math.randomInt", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		// Beyond 2^53 numbers are not all integers, and the size of the
+		// range could overflow.
+		if lo < -1<<53 || hi > 1<<53 {
+			return nil, fmt.Errorf("math.randomInt: bounds must be between -2^53 and 2^53.")
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("math.randomInt: lower bound %d is greater than upper bound %d.", lo, hi)
+		}
+		return float64(lo + i.rand.IntN(hi-lo+1)), nil
+	})
+	members["seed"] = NewNative("math.seed", 1, func(i *Interpreter, args []any) (any, error) {
+		seed, err := integerArg("math.seed", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		i.SetRandomSeed(uint64(seed))
+		return nil, nil
+	})
+	return NewNativeModule("math", members)
+}
+
+// fold reduces at least one number argument with fn.
+func fold(name string, args []any, fn func(float64, float64) float64) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: expected at least 1 argument.", name)
+	}
+	acc, err := numberArg(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for idx := 1; idx < len(args); idx++ {
+		x, err := numberArg(name, args, idx)
+		if err != nil {
+			return nil, err
+		}
+		acc = fn(acc, x)
+	}
+	return acc, nil
+}
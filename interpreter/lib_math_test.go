@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"lox/errors"
+	"lox/parser"
+	"lox/scanner"
+	"strings"
+	"testing"
+)
+
+// evalSource runs source in interp and returns the value of its last
+// expression statement.
+func evalSource(t *testing.T, interp *Interpreter, source string) (any, error) {
+	t.Helper()
+	stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+	if len(errors.Errors) != 0 {
+		defer func() { errors.Errors = errors.Errors[:0] }()
+		t.Fatalf("%s: %v", source, errors.Errors)
+	}
+	return interp.Run(stmts)
+}
+func TestMath(t *testing.T) {
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`math.sqrt(16);`, 4.0},
+		{`math.pow(2, 10);`, 1024.0},
+		{`math.abs(-3);`, 3.0},
+		{`math.max(3, 9, 4);`, 9.0},
+		{`math.min(3, 9, 4);`, 3.0},
+		{`math.isNaN(math.NAN);`, true},
+		{`math.isNaN("NaN");`, false},
+		{`import "math" as m; m == math;`, true},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %v, got %v (%v)", test.source, test.want, got, err)
+		}
+	}
+}
+func TestRandomSeed(t *testing.T) {
+	source := `math.random() + math.randomInt(1, 1000) * 1000;`
+	a := NewInterpreter(NewEnvironment(nil))
+	a.SetRandomSeed(7)
+	b := NewInterpreter(NewEnvironment(nil))
+	b.SetRandomSeed(7)
+	for n := 0; n < 5; n++ {
+		x, _ := evalSource(t, a, source)
+		y, _ := evalSource(t, b, source)
+		if x != y {
+			t.Fatalf("seeded interpreters diverged: %v != %v", x, y)
+		}
+	}
+	if _, err := evalSource(t, a, `math.randomInt(5, 1);`); err == nil {
+		t.Errorf("expected an error for an empty range")
+	}
+	if _, err := evalSource(t, a, `math.randomInt(-9000000000000000000, 9000000000000000000);`); err == nil || !strings.Contains(err.Error(), "bounds must be") {
+		t.Errorf("expected an error for bounds beyond 2^53, got %v", err)
+	}
+	got, err := evalSource(t, a, `math.randomInt(-9007199254740992, 9007199254740992);`)
+	if v, ok := got.(float64); err != nil || !ok || v < -1<<53 || v > 1<<53 {
+		t.Errorf("expected a number within the widest bounds, got %v (%v)", got, err)
+	}
+}
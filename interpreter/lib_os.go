@@ -0,0 +1,157 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Capability is a set of privileges the host grants to scripts. Natives
+// that touch the outside world refuse to run until the matching
+// capability has been granted with Grant.
+type Capability uint
+
+const (
+	// CapFS allows reading and writing files.
+	CapFS Capability = 1 << iota
+	// CapOS allows reading the environment and the script arguments and
+	// exiting the process.
+	CapOS
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapFS:
+		return "filesystem"
+	case CapOS:
+		return "OS"
+	default:
+		return fmt.Sprintf("Capability(%d)", uint(c))
+	}
+}
+
+// Exit is returned by the exit native. It unwinds the interpreter like an
+// error and the host decides how to end the process.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Grant enables the natives guarded by caps.
+func (i *Interpreter) Grant(caps Capability) {
+	i.caps |= caps
+}
+
+// SetArgs sets the list returned by the args native.
+func (i *Interpreter) SetArgs(args []string) {
+	i.args = args
+}
+
+// defineGuarded defines a native that fails unless cap has been granted.
+func (i *Interpreter) defineGuarded(cap Capability, name string, arity int, fn func(i *Interpreter, args []any) (any, error)) {
+	i.DefineNative(name, arity, func(i *Interpreter, args []any) (any, error) {
+		if i.caps&cap == 0 {
+			return nil, fmt.Errorf("%s: %s access is not enabled.", name, cap)
+		}
+		return fn(i, args)
+	})
+}
+func (i *Interpreter) defineOSNatives() {
+	i.defineGuarded(CapFS, "readFile", 1, func(_ *Interpreter, args []any) (any, error) {
+		path, err := stringArg("readFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("readFile: %s.", err)
+		}
+		return string(bs), nil
+	})
+	i.defineGuarded(CapFS, "readLines", 1, func(_ *Interpreter, args []any) (any, error) {
+		path, err := stringArg("readLines", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("readLines: %s.", err)
+		}
+		text := strings.TrimSuffix(strings.ReplaceAll(string(bs), "\r\n", "\n"), "\n")
+		if text == "" {
+			return NewList(nil), nil
+		}
+		return stringList(strings.Split(text, "\n")), nil
+	})
+	i.defineGuarded(CapFS, "writeFile", 2, func(_ *Interpreter, args []any) (any, error) {
+		return nil, writeFile("writeFile", args, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	})
+	i.defineGuarded(CapFS, "appendFile", 2, func(_ *Interpreter, args []any) (any, error) {
+		return nil, writeFile("appendFile", args, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	})
+	i.defineGuarded(CapFS, "listDir", 1, func(_ *Interpreter, args []any) (any, error) {
+		path, err := stringArg("listDir", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("listDir: %s.", err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return stringList(names), nil
+	})
+	i.defineGuarded(CapFS, "exists", 1, func(_ *Interpreter, args []any) (any, error) {
+		path, err := stringArg("exists", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		_, err = os.Stat(path)
+		return err == nil, nil
+	})
+	i.defineGuarded(CapOS, "getenv", 1, func(_ *Interpreter, args []any) (any, error) {
+		name, err := stringArg("getenv", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		}
+		return nil, nil
+	})
+	i.defineGuarded(CapOS, "args", 0, func(i *Interpreter, _ []any) (any, error) {
+		return stringList(i.args), nil
+	})
+	i.defineGuarded(CapOS, "exit", 1, func(_ *Interpreter, args []any) (any, error) {
+		code, err := integerArg("exit", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &Exit{Code: code}
+	})
+}
+func writeFile(name string, args []any, flag int) error {
+	path, err := stringArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	text, err := stringArg(name, args, 1)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return fmt.Errorf("%s: %s.", name, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		return fmt.Errorf("%s: %s.", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	sources := []string{
+		`readFile("x");`,
+		`writeFile("x", "y");`,
+		`listDir(".");`,
+		`exists(".");`,
+		`getenv("HOME");`,
+		`args();`,
+		`exit(1);`,
+	}
+	for _, source := range sources {
+		_, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), source)
+		if err == nil || !strings.Contains(err.Error(), "access is not enabled") {
+			t.Errorf("%s: expected a capability error, got %v", source, err)
+		}
+	}
+}
+func TestFileNatives(t *testing.T) {
+	dir := t.TempDir()
+	interp := NewInterpreter(NewEnvironment(nil))
+	interp.Grant(CapFS | CapOS)
+	interp.SetArgs([]string{"a", "b"})
+	interp.globals.define("dir", dir)
+	interp.globals.define("path", filepath.Join(dir, "out.txt"))
+
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`exists(path);`, false},
+		{`writeFile(path, "a"); appendFile(path, "b"); readFile(path);`, "ab"},
+		{`readLines(path)[0];`, "ab"},
+		{`listDir(dir)[0];`, "out.txt"},
+		{`args()[1];`, "b"},
+		{`getenv("LOX_TEST_UNSET_VARIABLE");`, nil},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, interp, test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %v, got %v (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	_, err := evalSource(t, interp, `fun quit() { exit(4); } quit(); print "unreachable";`)
+	var exit *Exit
+	if !errors.As(err, &exit) || exit.Code != 4 {
+		t.Errorf("expected exit status 4, got %v", err)
+	}
+}
+
+func TestFileImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.lox"), []byte(`export var secret = "s3cr3t";`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := `import "secret.lox" as s; import "secret.lox" as again; s == again ? s.secret : nil;`
+
+	interp := NewInterpreter(NewEnvironment(nil))
+	interp.SetFile(filepath.Join(dir, "main.lox"))
+	_, err := evalSource(t, interp, source)
+	if err == nil || !strings.Contains(err.Error(), "Cannot import 'secret.lox': filesystem access is not enabled.") {
+		t.Errorf("expected a capability error, got %v", err)
+	}
+	if _, err := evalSource(t, interp, `import "/etc/hostname" as h;`); err == nil || !strings.Contains(err.Error(), "access is not enabled") {
+		t.Errorf("expected a capability error for an absolute path, got %v", err)
+	}
+
+	interp.Grant(CapFS)
+	got, err := evalSource(t, interp, source)
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("expected the module to be imported once granted, got %v (%v)", got, err)
+	}
+}
@@ -0,0 +1,144 @@
+package interpreter
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Regex is a compiled regular expression, created by re.compile.
+type Regex struct {
+	re *regexp.Regexp
+}
+
+func (r *Regex) String() string {
+	return "<regex " + r.re.String() + ">"
+}
+func reModule() *Module {
+	return NewNativeModule("re", map[string]any{
+		"compile": NewNative("re.compile", 1, func(_ *Interpreter, args []any) (any, error) {
+			pattern, err := stringArg("re.compile", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("re.compile: %s.", err)
+			}
+			return &Regex{re: re}, nil
+		}),
+		"escape": NewNative("re.escape", 1, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg("re.escape", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return regexp.QuoteMeta(s), nil
+		}),
+	})
+}
+
+// regexMethod binds the method name to r. Methods that take a count
+// limit the number of matches; a negative or missing count means all.
+func regexMethod(r *Regex, name string) (any, bool) {
+	switch name {
+	case "pattern":
+		return r.re.String(), true
+	case "match":
+		return NewNative(name, 1, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return r.re.MatchString(s), nil
+		}), true
+	case "find":
+		return NewNative(name, 1, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			loc := r.re.FindStringSubmatchIndex(s)
+			if loc == nil {
+				return nil, nil
+			}
+			return r.match(s, loc), nil
+		}), true
+	case "findAll":
+		return NewNative(name, -1, func(_ *Interpreter, args []any) (any, error) {
+			s, n, err := textAndCount(name, args)
+			if err != nil {
+				return nil, err
+			}
+			matches := make([]any, 0)
+			for _, loc := range r.re.FindAllStringSubmatchIndex(s, n) {
+				matches = append(matches, r.match(s, loc))
+			}
+			return NewList(matches), nil
+		}), true
+	case "replace":
+		return NewNative(name, 2, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			repl, err := stringArg(name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			// repl may refer to groups as $1 or ${name}.
+			return r.re.ReplaceAllString(s, repl), nil
+		}), true
+	case "split":
+		return NewNative(name, -1, func(_ *Interpreter, args []any) (any, error) {
+			s, n, err := textAndCount(name, args)
+			if err != nil {
+				return nil, err
+			}
+			return stringList(r.re.Split(s, n)), nil
+		}), true
+	default:
+		return nil, false
+	}
+}
+
+// match describes one match as a map with the matched "text", its
+// "start" and "end" as rune offsets into s, the numbered "groups" and
+// the "named" groups. Groups that did not take part in the match are nil.
+func (r *Regex) match(s string, loc []int) *Map {
+	groups := make([]any, 0, len(loc)/2-1)
+	named := NewMap()
+	names := r.re.SubexpNames()
+	for idx := 1; idx < len(loc)/2; idx++ {
+		var group any
+		if loc[2*idx] >= 0 {
+			group = s[loc[2*idx]:loc[2*idx+1]]
+		}
+		groups = append(groups, group)
+		if names[idx] != "" {
+			named.Set(names[idx], group)
+		}
+	}
+	m := NewMap()
+	m.Set("text", s[loc[0]:loc[1]])
+	m.Set("start", float64(utf8.RuneCountInString(s[:loc[0]])))
+	m.Set("end", float64(utf8.RuneCountInString(s[:loc[1]])))
+	m.Set("groups", NewList(groups))
+	m.Set("named", named)
+	return m
+}
+func textAndCount(name string, args []any) (string, int, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", 0, fmt.Errorf("%s: expected 1 or 2 arguments but got %d.", name, len(args))
+	}
+	s, err := stringArg(name, args, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	n := -1
+	if len(args) == 2 {
+		if n, err = integerArg(name, args, 1); err != nil {
+			return "", 0, err
+		}
+	}
+	return s, n, nil
+}
@@ -0,0 +1,36 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`re.compile("^a+$").match("aaa");`, true},
+		{`re.compile("b").find("abc").start;`, 1.0},
+		{`re.compile("é(x)").find("aéx").end;`, 3.0},
+		{`re.compile("x").find("abc");`, nil},
+		{`re.compile("(a)|(b)").find("b").groups[0];`, nil},
+		{`re.compile("(?P<k>[a-z]+)=(?P<v>[0-9]+)").find("x=1").named.v;`, "1"},
+		{`re.compile("[0-9]+").findAll("1 22 333", 2)[1].text;`, "22"},
+		{`re.compile("(\w+)@(\w+)").replace("me@home", "$2 at $1");`, "home at me"},
+		{`re.compile(",").split("a,b,c").len();`, 3.0},
+		{`re.compile(re.escape("a.b")).match("axb");`, false},
+		{`re.compile("a+").pattern;`, "a+"},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %v, got %v (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	_, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), `re.compile("(");`)
+	if err == nil || !strings.Contains(err.Error(), "re.compile: error parsing regexp") {
+		t.Errorf("expected a compile error, got %v", err)
+	}
+}
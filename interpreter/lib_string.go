@@ -0,0 +1,230 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringFunc is a function of the string module. The string it works on
+// is passed separately from the remaining arguments, whose count must lie
+// between min and max.
+type stringFunc struct {
+	min, max int
+	fn       func(name, s string, args []any) (any, error)
+}
+
+// stringFuncs back both the string module, where the string is the first
+// argument, and the methods of string values, where it is the receiver.
+var stringFuncs = map[string]stringFunc{
+	"len": {0, 0, func(_, s string, _ []any) (any, error) {
+		return float64(utf8.RuneCountInString(s)), nil
+	}},
+	"upper": {0, 0, func(_, s string, _ []any) (any, error) {
+		return strings.ToUpper(s), nil
+	}},
+	"lower": {0, 0, func(_, s string, _ []any) (any, error) {
+		return strings.ToLower(s), nil
+	}},
+	"trim": {0, 0, func(_, s string, _ []any) (any, error) {
+		return strings.TrimSpace(s), nil
+	}},
+	"split": {1, 1, func(name, s string, args []any) (any, error) {
+		sep, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return stringList(strings.Split(s, sep)), nil
+	}},
+	// join is called on the separator, as in ", ".join(names).
+	"join": {1, 1, func(name, s string, args []any) (any, error) {
+		list, ok := args[0].(*List)
+		if !ok {
+			return nil, fmt.Errorf("%s: argument must be a list.", name)
+		}
+		parts := make([]string, 0, len(list.elements))
+		for _, element := range list.elements {
+			parts = append(parts, stringify(element))
+		}
+		return strings.Join(parts, s), nil
+	}},
+	"replace": {2, 2, func(name, s string, args []any) (any, error) {
+		old, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		repl, err := stringArg(name, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s, old, repl), nil
+	}},
+	"contains": {1, 1, func(name, s string, args []any) (any, error) {
+		sub, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(s, sub), nil
+	}},
+	"startsWith": {1, 1, func(name, s string, args []any) (any, error) {
+		prefix, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}},
+	"endsWith": {1, 1, func(name, s string, args []any) (any, error) {
+		suffix, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(s, suffix), nil
+	}},
+	// indexOf counts runes, so its result can be used to index s.
+	"indexOf": {1, 1, func(name, s string, args []any) (any, error) {
+		sub, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		idx := strings.Index(s, sub)
+		if idx < 0 {
+			return -1.0, nil
+		}
+		return float64(utf8.RuneCountInString(s[:idx])), nil
+	}},
+	"substring": {1, 2, func(name, s string, args []any) (any, error) {
+		runes := []rune(s)
+		start, err := integerArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		end := len(runes)
+		if len(args) > 1 {
+			if end, err = integerArg(name, args, 1); err != nil {
+				return nil, err
+			}
+		}
+		if start < 0 || end > len(runes) || start > end {
+			return nil, fmt.Errorf("%s: range [%d, %d) out of bounds for length %d.", name, start, end, len(runes))
+		}
+		return string(runes[start:end]), nil
+	}},
+	"repeat": {1, 1, func(name, s string, args []any) (any, error) {
+		n, err := integerArg(name, args, 0)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: count must be a non-negative integer.", name)
+		}
+		if len(s) > 0 && n > maxStringLength/len(s) {
+			return nil, fmt.Errorf("%s: result would be longer than %d bytes.", name, maxStringLength)
+		}
+		return strings.Repeat(s, n), nil
+	}},
+	"padLeft": {1, 2, func(name, s string, args []any) (any, error) {
+		padding, err := pad(name, s, args)
+		if err != nil {
+			return nil, err
+		}
+		return padding + s, nil
+	}},
+	"padRight": {1, 2, func(name, s string, args []any) (any, error) {
+		padding, err := pad(name, s, args)
+		if err != nil {
+			return nil, err
+		}
+		return s + padding, nil
+	}},
+	"chars": {0, 0, func(_, s string, _ []any) (any, error) {
+		chars := make([]string, 0, len(s))
+		for _, r := range s {
+			chars = append(chars, string(r))
+		}
+		return stringList(chars), nil
+	}},
+	// parseNumber returns nil rather than failing, so scripts can test
+	// untrusted input without aborting.
+	"parseNumber": {0, 0, func(_, s string, _ []any) (any, error) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, nil
+		}
+		return f, nil
+	}},
+}
+
+// maxStringLength is the longest string, in bytes, that repeat and pad
+// build, so that huge counts fail instead of exhausting memory.
+const maxStringLength = 1 << 30
+
+// pad returns the padding that brings s up to the width in args[0],
+// built from the optional fill string in args[1].
+func pad(name, s string, args []any) (string, error) {
+	width, err := integerArg(name, args, 0)
+	if err != nil {
+		return "", err
+	}
+	fill := " "
+	if len(args) > 1 {
+		if fill, err = stringArg(name, args, 1); err != nil {
+			return "", err
+		}
+		if fill == "" {
+			return "", fmt.Errorf("%s: fill must not be empty.", name)
+		}
+	}
+	missing := width - utf8.RuneCountInString(s)
+	if missing <= 0 {
+		return "", nil
+	}
+	if missing > maxStringLength/len(fill) {
+		return "", fmt.Errorf("%s: result would be longer than %d bytes.", name, maxStringLength)
+	}
+	fills := []rune(strings.Repeat(fill, missing))
+	return string(fills[:missing]), nil
+}
+func stringList(parts []string) *List {
+	elements := make([]any, 0, len(parts))
+	for _, part := range parts {
+		elements = append(elements, part)
+	}
+	return NewList(elements)
+}
+
+// call checks the argument count and runs the function.
+func (f stringFunc) call(name, s string, args []any) (any, error) {
+	if len(args) < f.min || len(args) > f.max {
+		if f.min == f.max {
+			return nil, fmt.Errorf("%s: expected %d arguments but got %d.", name, f.min, len(args))
+		}
+		return nil, fmt.Errorf("%s: expected %d to %d arguments but got %d.", name, f.min, f.max, len(args))
+	}
+	return f.fn(name, s, args)
+}
+func stringModule() *Module {
+	members := make(map[string]any, len(stringFuncs))
+	for name, f := range stringFuncs {
+		qualified := "string." + name
+		members[name] = NewNative(qualified, -1, func(_ *Interpreter, args []any) (any, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: expected a string argument.", qualified)
+			}
+			s, err := stringArg(qualified, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return f.call(qualified, s, args[1:])
+		})
+	}
+	return NewNativeModule("string", members)
+}
+
+// stringMethod binds the string function name to receiver s.
+func stringMethod(s string, name string) (*Native, bool) {
+	f, ok := stringFuncs[name]
+	if !ok {
+		return nil, false
+	}
+	return NewNative(name, -1, func(_ *Interpreter, args []any) (any, error) {
+		return f.call(name, s, args)
+	}), true
+}
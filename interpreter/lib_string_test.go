@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`"héllo".len();`, 5.0},
+		{`string.len("héllo");`, 5.0},
+		{`"héllo"[1];`, "é"},
+		{`" x ".trim().upper();`, "X"},
+		{`"-".join("a b c".split(" "));`, "a-b-c"},
+		{`"banana".replace("na", "");`, "ba"},
+		{`"héllo".indexOf("l");`, 2.0},
+		{`"héllo".indexOf("z");`, -1.0},
+		{`"héllo".substring(1, 3);`, "él"},
+		{`"héllo".substring(3);`, "lo"},
+		{`"5".padLeft(3, "0");`, "005"},
+		{`"5".padRight(4, "ab");`, "5aba"},
+		{`"abc".chars()[2];`, "c"},
+		{`"42".parseNumber();`, 42.0},
+		{`"forty".parseNumber();`, nil},
+		{`var xs = [1, 2]; xs[1] *= 5; xs[1];`, 10.0},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %v, got %v (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	errs := []string{
+		`"abc"[3];`,
+		`"abc"[0] = "x";`,
+		`"abc".substring(2, 1);`,
+		`"abc".split();`,
+		`string.upper(1);`,
+		`"abc".nope();`,
+	}
+	for _, source := range errs {
+		if _, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), source); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+
+	// Huge counts fail instead of exhausting memory.
+	limits := []struct {
+		source string
+		err    string
+	}{
+		{`"ab".repeat(9000000000000000000);`, "repeat: result would be longer than"},
+		{`"ab".padLeft(9000000000000000000);`, "padLeft: result would be longer than"},
+		{`"ab".padRight(2000000000, "xy");`, "padRight: result would be longer than"},
+	}
+	for _, test := range limits {
+		if _, err := evalSource(t, NewInterpreter(NewEnvironment(nil)), test.source); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.source, test.err, err)
+		}
+	}
+}
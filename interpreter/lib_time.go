@@ -0,0 +1,124 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SetClock replaces the source of the current time for clock, now and
+// the time module, so tests can freeze or step time.
+func (i *Interpreter) SetClock(now func() time.Time) {
+	i.now = now
+}
+
+// Timestamps are numbers of seconds since the Unix epoch and durations
+// are numbers of seconds, so ordinary arithmetic works on both. Calendar
+// functions use UTC.
+func toTimestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+func fromTimestamp(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC()
+}
+func (i *Interpreter) defineTimeNatives() {
+	i.DefineNative("clock", 0, func(i *Interpreter, _ []any) (any, error) {
+		return toTimestamp(i.now()), nil
+	})
+	now := NewNative("now", 0, func(i *Interpreter, _ []any) (any, error) {
+		return toTimestamp(i.now()), nil
+	})
+	i.globals.define("now", now)
+	i.DefineNativeModule("time", NewNativeModule("time", map[string]any{
+		"now":      now,
+		"SECOND":   1.0,
+		"MINUTE":   60.0,
+		"HOUR":     3600.0,
+		"DAY":      86400.0,
+		"RFC3339":  time.RFC3339,
+		"DATE":     time.DateOnly,
+		"TIME":     time.TimeOnly,
+		"DATETIME": time.DateTime,
+		"format": NewNative("time.format", -1, func(_ *Interpreter, args []any) (any, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("time.format: expected 1 or 2 arguments but got %d.", len(args))
+			}
+			ts, err := numberArg("time.format", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			layout := time.RFC3339
+			if len(args) == 2 {
+				if layout, err = stringArg("time.format", args, 1); err != nil {
+					return nil, err
+				}
+			}
+			return fromTimestamp(ts).Format(layout), nil
+		}),
+		"parse": NewNative("time.parse", 2, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg("time.parse", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			layout, err := stringArg("time.parse", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			t, err := time.Parse(layout, s)
+			if err != nil {
+				return nil, fmt.Errorf("time.parse: %s.", err)
+			}
+			return toTimestamp(t), nil
+		}),
+		"date": NewNative("time.date", -1, func(_ *Interpreter, args []any) (any, error) {
+			if len(args) < 3 || len(args) > 6 {
+				return nil, fmt.Errorf("time.date: expected 3 to 6 arguments but got %d.", len(args))
+			}
+			parts := [6]int{}
+			for idx := range args {
+				n, err := integerArg("time.date", args, idx)
+				if err != nil {
+					return nil, err
+				}
+				parts[idx] = n
+			}
+			t := time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], 0, time.UTC)
+			return toTimestamp(t), nil
+		}),
+		"parts": NewNative("time.parts", 1, func(_ *Interpreter, args []any) (any, error) {
+			ts, err := numberArg("time.parts", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			t := fromTimestamp(ts)
+			m := NewMap()
+			m.Set("year", float64(t.Year()))
+			m.Set("month", float64(t.Month()))
+			m.Set("day", float64(t.Day()))
+			m.Set("hour", float64(t.Hour()))
+			m.Set("minute", float64(t.Minute()))
+			m.Set("second", float64(t.Second()))
+			m.Set("weekday", t.Weekday().String())
+			return m, nil
+		}),
+		"duration": NewNative("time.duration", 1, func(_ *Interpreter, args []any) (any, error) {
+			s, err := stringArg("time.duration", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("time.duration: %s.", err)
+			}
+			return d.Seconds(), nil
+		}),
+		"formatDuration": NewNative("time.formatDuration", 1, func(_ *Interpreter, args []any) (any, error) {
+			secs, err := numberArg("time.formatDuration", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return time.Duration(secs * float64(time.Second)).String(), nil
+		}),
+	}))
+}
@@ -0,0 +1,44 @@
+package interpreter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrozenClock(t *testing.T) {
+	frozen := time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+	interp := NewInterpreter(NewEnvironment(nil))
+	interp.SetClock(func() time.Time { return frozen })
+
+	tests := []struct {
+		source string
+		want   any
+	}{
+		{`clock();`, 1710495000.0},
+		{`now() == time.now();`, true},
+		{`time.format(now() + time.HOUR);`, "2024-03-15T10:30:00Z"},
+		{`time.format(now(), "02 Jan 2006");`, "15 Mar 2024"},
+		{`time.parse("2024-03-16", time.DATE) - now();`, 14.5 * 3600},
+		{`time.date(2024, 3, 15, 9, 30) == now();`, true},
+		{`time.parts(now()).month;`, 3.0},
+		{`time.duration("1m30s");`, 90.0},
+		{`time.formatDuration(90);`, "1m30s"},
+	}
+	for _, test := range tests {
+		got, err := evalSource(t, interp, test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %v, got %v (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	errs := []string{
+		`time.parse("tomorrow", time.DATE);`,
+		`time.duration("soon");`,
+		`time.date(2024, 3);`,
+	}
+	for _, source := range errs {
+		if _, err := evalSource(t, interp, source); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+}
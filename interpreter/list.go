@@ -0,0 +1,162 @@
+package interpreter
+
+import (
+	"fmt"
+	"lox/errors"
+	"lox/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// List is the value of a list literal. Lists are mutable and compared by
+// identity.
+type List struct {
+	elements []any
+}
+
+func NewList(elements []any) *List {
+	return &List{elements: elements}
+}
+
+// Elements returns the elements of the list, which it shares with the
+// caller.
+func (l *List) Elements() []any {
+	return l.elements
+}
+func (l *List) String() string {
+	return repr(l, nil)
+}
+
+// Repr renders a value the way the prompt echoes it: like print, except
+// that strings are quoted.
+func Repr(val any) string {
+	return repr(val, nil)
+}
+
+// maxNesting is the deepest nesting of collections that is printed,
+// stringified or parsed, so that deep values fail instead of overflowing
+// the Go stack.
+const maxNesting = 10000
+
+// repr renders a value nested in a collection. Strings are quoted so that
+// ["a, b"] and ["a", "b"] print differently, and seen holds the enclosing
+// collections so that one containing itself, or nested deeper than
+// maxNesting, prints as [...] or {...}.
+func repr(val any, seen []any) string {
+	switch val := val.(type) {
+	case string:
+		return strconv.Quote(val)
+	case *List:
+		if len(seen) >= maxNesting || slices.Contains(seen, any(val)) {
+			return "[...]"
+		}
+		seen = append(seen, val)
+		parts := make([]string, 0, len(val.elements))
+		for _, element := range val.elements {
+			parts = append(parts, repr(element, seen))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *Map:
+		if len(seen) >= maxNesting || slices.Contains(seen, any(val)) {
+			return "{...}"
+		}
+		seen = append(seen, val)
+		parts := make([]string, 0, len(val.keys))
+		for _, key := range val.keys {
+			parts = append(parts, repr(key, seen)+": "+repr(val.values[key], seen))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return stringify(val)
+	}
+}
+
+// listMethod binds the method name to list l.
+func listMethod(l *List, name string) (*Native, bool) {
+	switch name {
+	case "len":
+		return NewNative(name, 0, func(_ *Interpreter, _ []any) (any, error) {
+			return float64(len(l.elements)), nil
+		}), true
+	case "push":
+		return NewNative(name, 1, func(_ *Interpreter, args []any) (any, error) {
+			l.elements = append(l.elements, args[0])
+			return float64(len(l.elements)), nil
+		}), true
+	case "pop":
+		return NewNative(name, 0, func(_ *Interpreter, _ []any) (any, error) {
+			if len(l.elements) == 0 {
+				return nil, fmt.Errorf("pop: list is empty.")
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last, nil
+		}), true
+	default:
+		return nil, false
+	}
+}
+
+// indexGet evaluates object[index] for lists and strings. Strings are
+// indexed by rune, not by byte.
+func indexGet(bracket token.Token, object, index any) (any, error) {
+	switch object := object.(type) {
+	case *Map:
+		if err := checkKey(index); err != nil {
+			return nil, errors.NewRuntimeError(bracket, err.Error())
+		}
+		value, ok := object.Get(index)
+		if !ok {
+			return nil, errors.NewRuntimeError(bracket, fmt.Sprintf("Undefined key %s.", repr(index, nil)))
+		}
+		return value, nil
+	case *List:
+		idx, err := listIndex(bracket, index, len(object.elements))
+		if err != nil {
+			return nil, err
+		}
+		return object.elements[idx], nil
+	case string:
+		runes := []rune(object)
+		idx, err := listIndex(bracket, index, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return string(runes[idx]), nil
+	default:
+		return nil, errors.NewRuntimeError(bracket, "Only lists, maps and strings can be indexed.")
+	}
+}
+func indexSet(bracket token.Token, object, index, value any) (any, error) {
+	switch object := object.(type) {
+	case *Map:
+		if err := checkKey(index); err != nil {
+			return nil, errors.NewRuntimeError(bracket, err.Error())
+		}
+		object.Set(index, value)
+		return value, nil
+	case *List:
+		idx, err := listIndex(bracket, index, len(object.elements))
+		if err != nil {
+			return nil, err
+		}
+		object.elements[idx] = value
+		return value, nil
+	case string:
+		return nil, errors.NewRuntimeError(bracket, "Strings are immutable.")
+	default:
+		return nil, errors.NewRuntimeError(bracket, "Only lists and maps can be assigned by index.")
+	}
+}
+func listIndex(bracket token.Token, index any, length int) (int, error) {
+	f, ok := index.(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, errors.NewRuntimeError(bracket, "Index must be an integer.")
+	}
+	idx := int(f)
+	if idx < 0 || idx >= length {
+		return 0, errors.NewRuntimeError(bracket, fmt.Sprintf("Index %d out of range for length %d.", idx, length))
+	}
+	return idx, nil
+}
@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Map is the value of a map literal. Entries keep their insertion order,
+// which is also the order in which they print and serialize. Keys are
+// strings, numbers, booleans or nil.
+type Map struct {
+	keys   []any
+	values map[any]any
+}
+
+func NewMap() *Map {
+	return &Map{values: make(map[any]any)}
+}
+func (m *Map) Get(key any) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+func (m *Map) Set(key, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+func (m *Map) Delete(key any) bool {
+	if _, ok := m.values[key]; !ok {
+		return false
+	}
+	delete(m.values, key)
+	m.keys = slices.DeleteFunc(m.keys, func(k any) bool { return k == key })
+	return true
+}
+
+// Keys returns the keys of the map in insertion order.
+func (m *Map) Keys() []any {
+	return slices.Clone(m.keys)
+}
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+func (m *Map) String() string {
+	return repr(m, nil)
+}
+func checkKey(key any) error {
+	switch key.(type) {
+	case nil, bool, float64, string:
+		return nil
+	default:
+		return fmt.Errorf("Map keys must be strings, numbers, booleans or nil.")
+	}
+}
+
+// mapMethod binds the method name to map m. Entries whose key is a string
+// are also reachable as m.key and take precedence over these methods.
+func mapMethod(m *Map, name string) (*Native, bool) {
+	switch name {
+	case "len":
+		return NewNative(name, 0, func(_ *Interpreter, _ []any) (any, error) {
+			return float64(m.Len()), nil
+		}), true
+	case "keys":
+		return NewNative(name, 0, func(_ *Interpreter, _ []any) (any, error) {
+			return NewList(slices.Clone(m.keys)), nil
+		}), true
+	case "values":
+		return NewNative(name, 0, func(_ *Interpreter, _ []any) (any, error) {
+			values := make([]any, 0, m.Len())
+			for _, key := range m.keys {
+				values = append(values, m.values[key])
+			}
+			return NewList(values), nil
+		}), true
+	case "has":
+		return NewNative(name, 1, func(_ *Interpreter, args []any) (any, error) {
+			_, ok := m.values[args[0]]
+			return ok, checkKey(args[0])
+		}), true
+	case "remove":
+		return NewNative(name, 1, func(_ *Interpreter, args []any) (any, error) {
+			if err := checkKey(args[0]); err != nil {
+				return nil, err
+			}
+			return m.Delete(args[0]), nil
+		}), true
+	default:
+		return nil, false
+	}
+}
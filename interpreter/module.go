@@ -0,0 +1,184 @@
+package interpreter
+
+import (
+	"fmt"
+	"io/fs"
+	"lox/ast"
+	"lox/errors"
+	"lox/parser"
+	"lox/resolver"
+	"lox/scanner"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ModuleLoader locates and reads the source of imported modules. Hosts set
+// one with SetModuleLoader to control where imports come from.
+type ModuleLoader interface {
+	// Resolve turns the path written in an import statement into a key
+	// that identifies the module. from is the key of the importing file,
+	// or "" for the main script when it has no file.
+	Resolve(from, path string) (string, error)
+	// Load returns the source of the module identified by key.
+	Load(key string) (string, error)
+}
+
+// FileLoader resolves imports on the filesystem, relative to the
+// directory of the importing file. It is the default loader, and imports
+// through it fail unless CapFS has been granted.
+type FileLoader struct{}
+
+func (FileLoader) Resolve(from, name string) (string, error) {
+	if !filepath.IsAbs(name) && from != "" {
+		name = filepath.Join(filepath.Dir(from), name)
+	}
+	return filepath.Abs(name)
+}
+func (FileLoader) Load(key string) (string, error) {
+	bs, err := os.ReadFile(key)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// DisplayName shortens the key of a file for messages and reports: a path
+// below the working directory becomes relative to it, and "" names
+// standard input.
+func DisplayName(key string) string {
+	if key == "" {
+		return "<stdin>"
+	}
+	if wd, err := os.Getwd(); err == nil && strings.HasPrefix(key, wd+string(os.PathSeparator)) {
+		return filepath.ToSlash(key[len(wd)+1:])
+	}
+	return key
+}
+
+// FSLoader resolves imports inside an fs.FS, such as an embed.FS, relative
+// to the importing file. Paths may not leave the root of FS.
+type FSLoader struct {
+	FS fs.FS
+}
+
+func (l FSLoader) Resolve(from, name string) (string, error) {
+	if from != "" && !strings.HasPrefix(name, "/") {
+		name = path.Join(path.Dir(from), name)
+	}
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid module path '%s'", name)
+	}
+	return name, nil
+}
+func (l FSLoader) Load(key string) (string, error) {
+	bs, err := fs.ReadFile(l.FS, key)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// Module is the value bound by an import statement. Its exports are read
+// from the module's own environment, so they observe later assignments
+// made by the module itself.
+type Module struct {
+	name    string
+	env     *Environment
+	exports map[string]bool
+}
+
+func (m *Module) get(name string) (any, bool) {
+	if !m.exports[name] {
+		return nil, false
+	}
+	value, ok := m.env.values[name]
+	return value, ok
+}
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+func (i *Interpreter) evalImport(stmt *ast.ImportStmt) error {
+	from := stmt.Path.Literal.(string)
+	if module, ok := i.natives[from]; ok {
+		i.env.defineConst(stmt.Name.Lexeme, module)
+		i.defined(stmt.Name, module)
+		return nil
+	}
+	if _, ok := i.loader.(FileLoader); ok && i.caps&CapFS == 0 {
+		return errors.NewRuntimeError(stmt.Path, fmt.Sprintf("Cannot import '%s': %s access is not enabled.", from, CapFS))
+	}
+	key, err := i.loader.Resolve(i.file, from)
+	if err != nil {
+		return errors.NewRuntimeError(stmt.Path, fmt.Sprintf("Cannot resolve module '%s': %s.", from, err))
+	}
+	module, err := i.loadModule(stmt, key)
+	if err != nil {
+		return err
+	}
+	i.env.defineConst(stmt.Name.Lexeme, module)
+	i.defined(stmt.Name, module)
+	return nil
+}
+func (i *Interpreter) loadModule(stmt *ast.ImportStmt, key string) (*Module, error) {
+	if module, ok := i.modules[key]; ok {
+		return module, nil
+	}
+	for idx, loading := range i.loading {
+		if loading == key {
+			cycle := slices.Concat(i.loading[idx:], []string{key})
+			return nil, errors.NewRuntimeError(stmt.Path, fmt.Sprintf("Import cycle: %s.", strings.Join(cycle, " -> ")))
+		}
+	}
+	source, err := i.loader.Load(key)
+	if err != nil {
+		return nil, errors.NewRuntimeError(stmt.Path, fmt.Sprintf("Cannot load module '%s': %s.", key, err))
+	}
+	stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+	resolver.NewResolver().Resolve(stmts)
+	if len(errors.Errors) != 0 {
+		msgs := make([]string, 0, len(errors.Errors))
+		for _, err := range errors.Errors {
+			msgs = append(msgs, err.Error())
+		}
+		errors.Errors = errors.Errors[:0]
+		return nil, errors.NewRuntimeError(stmt.Path, fmt.Sprintf("Error in module '%s':\n%s", key, strings.Join(msgs, "\n")))
+	}
+
+	module := &Module{
+		name:    key,
+		env:     NewEnvironment(i.globals),
+		exports: exportedNames(stmts),
+	}
+	file := i.file
+	i.file = key
+	i.loading = append(i.loading, key)
+	defer func() {
+		i.file = file
+		i.loading = i.loading[:len(i.loading)-1]
+	}()
+	if _, err := i.evalBlock(stmts, module.env); err != nil {
+		return nil, err
+	}
+	i.modules[key] = module
+	return module, nil
+}
+func exportedNames(stmts []ast.Stmt) map[string]bool {
+	names := make(map[string]bool)
+	for _, stmt := range stmts {
+		export, ok := stmt.(*ast.ExportStmt)
+		if !ok {
+			continue
+		}
+		switch decl := export.Decl.(type) {
+		case *ast.VariableStmt:
+			names[decl.Name.Lexeme] = true
+		case *ast.FunctionStmt:
+			names[decl.Name.Lexeme] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"lox/errors"
+	"lox/parser"
+	"lox/scanner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestImport(t *testing.T) {
+	files := fstest.MapFS{
+		"lib/math.lox": {Data: []byte(`import "util.lox" as util; export fun double(x) { return util.twice(x); }`)},
+		"lib/util.lox": {Data: []byte(`export fun twice(x) { return x * 2; } fun hidden() {}`)},
+		"cycle/a.lox":  {Data: []byte(`import "b.lox" as b;`)},
+		"cycle/b.lox":  {Data: []byte(`import "a.lox" as a;`)},
+		"broken/x.lox": {Data: []byte(`export var = 1;`)},
+		"counter.lox":  {Data: []byte(`export var count = 0; export fun inc() { count = count + 1; }`)},
+	}
+	tests := []struct {
+		source string
+		want   any
+		err    string
+	}{
+		{
+			source: `import "lib/math.lox" as m; m.double(21);`,
+			want:   42.0,
+		},
+		{
+			source: `import "counter.lox" as c; import "counter.lox" as d; c.inc(); d.inc(); c.count;`,
+			want:   2.0,
+		},
+		{
+			source: `import "lib/util.lox" as u; u.hidden;`,
+			err:    "has no export 'hidden'",
+		},
+		{
+			source: `import "cycle/a.lox" as a;`,
+			err:    "Import cycle: cycle/a.lox -> cycle/b.lox -> cycle/a.lox.",
+		},
+		{
+			source: `import "broken/x.lox" as x;`,
+			err:    "Error in module 'broken/x.lox'",
+		},
+		{
+			source: `import "../outside.lox" as x;`,
+			err:    "Cannot resolve module",
+		},
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter(NewEnvironment(nil))
+		interp.SetModuleLoader(FSLoader{FS: files})
+		interp.SetFile("main.lox")
+		stmts := parser.NewParser(scanner.NewSacnner(test.source).ScanTokens()).Parse()
+		got, err := interp.Run(stmts)
+		errors.Errors = errors.Errors[:0]
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected error containing %q, got %v", test.source, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.source, err)
+		} else if got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.source, test.want, got)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ key, want string }{
+		{"", "<stdin>"},
+		{filepath.Join(wd, "lib", "util.lox"), "lib/util.lox"},
+		{wd + "x/main.lox", wd + "x/main.lox"},
+		{"lib/util.lox", "lib/util.lox"},
+	}
+	for _, test := range tests {
+		if got := DisplayName(test.key); got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.key, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+)
+
+// Native is a builtin function implemented in Go. An arity of -1 accepts
+// any number of arguments and leaves the checking to fn.
+type Native struct {
+	name  string
+	arity int
+	fn    func(i *Interpreter, args []any) (any, error)
+}
+
+func NewNative(name string, arity int, fn func(i *Interpreter, args []any) (any, error)) *Native {
+	return &Native{
+		name:  name,
+		arity: arity,
+		fn:    fn,
+	}
+}
+func (n *Native) Arity() int {
+	return n.arity
+}
+func (n *Native) Call(i *Interpreter, args []any) (any, error) {
+	return n.fn(i, args)
+}
+func (n *Native) String() string {
+	return "<native fn " + n.name + ">"
+}
+
+// NewNativeModule bundles values into a module that exports all of them,
+// for libraries that are implemented in Go.
+func NewNativeModule(name string, members map[string]any) *Module {
+	env := NewEnvironment(nil)
+	exports := make(map[string]bool, len(members))
+	for key, value := range members {
+		env.defineConst(key, value)
+		exports[key] = true
+	}
+	return &Module{
+		name:    name,
+		env:     env,
+		exports: exports,
+	}
+}
+
+// DefineNativeModule makes module importable under name and binds it as
+// a global of the same name.
+func (i *Interpreter) DefineNativeModule(name string, module *Module) {
+	i.natives[name] = module
+	i.globals.defineConst(name, module)
+}
+
+// DefineNative binds a Go function as a global.
+func (i *Interpreter) DefineNative(name string, arity int, fn func(i *Interpreter, args []any) (any, error)) {
+	i.globals.define(name, NewNative(name, arity, fn))
+}
+
+// numberArg returns argument idx of a native call as a number.
+func numberArg(name string, args []any, idx int) (float64, error) {
+	if v, ok := args[idx].(float64); ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("%s: argument %d must be a number.", name, idx+1)
+}
+
+// integerArg returns argument idx of a native call as an integer.
+func integerArg(name string, args []any, idx int) (int, error) {
+	v, err := numberArg(name, args, idx)
+	if err != nil || v != float64(int(v)) {
+		return 0, fmt.Errorf("%s: argument %d must be an integer.", name, idx+1)
+	}
+	return int(v), nil
+}
+
+// stringArg returns argument idx of a native call as a string.
+func stringArg(name string, args []any, idx int) (string, error) {
+	if v, ok := args[idx].(string); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%s: argument %d must be a string.", name, idx+1)
+}
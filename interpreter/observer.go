@@ -0,0 +1,159 @@
+package interpreter
+
+import (
+	"lox/ast"
+	"lox/errors"
+	"lox/token"
+)
+
+// Observer follows the execution of a program, for debuggers, profilers
+// and the like. Its methods are called from the goroutine running the
+// program and may block, which pauses it until they return. A program
+// without an observer pays only for checking that it has none.
+type Observer interface {
+	// Statement is called before stmt runs in env. An error stops the
+	// program as if stmt had failed with it.
+	Statement(stmt ast.Stmt, env *Environment) error
+	// Expression is called after expr evaluated to value or failed with
+	// err.
+	Expression(expr ast.Expr, value any, err error)
+	// Call is called when fn starts running in env, which holds its
+	// parameters, and Return when it returns value or fails with err.
+	Call(fn *Function, env *Environment)
+	Return(fn *Function, value any, err error)
+	// Branch is called when the condition of node, an *ast.IfStmt or an
+	// *ast.ConditionNode, has chosen the first branch when taken is true
+	// and the other one otherwise.
+	Branch(node any, taken bool)
+	// Define is called when a declaration binds name to value in env,
+	// and Assign when an assignment in env stores value in the variable
+	// name, which may belong to an enclosing environment.
+	Define(name token.Token, value any, env *Environment)
+	Assign(name token.Token, value any, env *Environment)
+	// Error is called once for each runtime error, when the statement
+	// that raised it fails and before it unwinds the calls in progress.
+	Error(err *errors.RuntimeError)
+}
+
+// NopObserver ignores everything. Observers embed it to implement only
+// the methods they need.
+type NopObserver struct{}
+
+func (NopObserver) Statement(ast.Stmt, *Environment) error { return nil }
+func (NopObserver) Expression(ast.Expr, any, error)        {}
+func (NopObserver) Call(*Function, *Environment)           {}
+func (NopObserver) Return(*Function, any, error)           {}
+func (NopObserver) Branch(any, bool)                       {}
+func (NopObserver) Define(token.Token, any, *Environment)  {}
+func (NopObserver) Assign(token.Token, any, *Environment)  {}
+func (NopObserver) Error(*errors.RuntimeError)             {}
+
+// Observers tells each of its observers in turn. Statement stops at the
+// first error.
+type Observers []Observer
+
+func (o Observers) Statement(stmt ast.Stmt, env *Environment) error {
+	for _, observer := range o {
+		if err := observer.Statement(stmt, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (o Observers) Expression(expr ast.Expr, value any, err error) {
+	for _, observer := range o {
+		observer.Expression(expr, value, err)
+	}
+}
+func (o Observers) Call(fn *Function, env *Environment) {
+	for _, observer := range o {
+		observer.Call(fn, env)
+	}
+}
+func (o Observers) Return(fn *Function, value any, err error) {
+	for _, observer := range o {
+		observer.Return(fn, value, err)
+	}
+}
+func (o Observers) Branch(node any, taken bool) {
+	for _, observer := range o {
+		observer.Branch(node, taken)
+	}
+}
+func (o Observers) Define(name token.Token, value any, env *Environment) {
+	for _, observer := range o {
+		observer.Define(name, value, env)
+	}
+}
+func (o Observers) Assign(name token.Token, value any, env *Environment) {
+	for _, observer := range o {
+		observer.Assign(name, value, env)
+	}
+}
+func (o Observers) Error(err *errors.RuntimeError) {
+	for _, observer := range o {
+		observer.Error(err)
+	}
+}
+
+// SetObserver makes o follow the execution of the program, or stops
+// following it when o is nil.
+func (i *Interpreter) SetObserver(o Observer) {
+	i.observer = o
+}
+
+// Evaluate evaluates expr in env, such as the environment of a paused
+// function. The observer is not told about code it runs.
+func (i *Interpreter) Evaluate(expr ast.Expr, env *Environment) (any, error) {
+	previous, observer := i.env, i.observer
+	i.env, i.observer = env, nil
+	defer func() { i.env, i.observer = previous, observer }()
+	return i.eval(expr)
+}
+
+// observeStatement runs stmt, telling the observer about it and about
+// the runtime error it raises, if any.
+func (i *Interpreter) observeStatement(stmt ast.Stmt) (any, error) {
+	observer := i.observer
+	if err := observer.Statement(stmt, i.env); err != nil {
+		return nil, err
+	}
+	ret, err := i.execute(stmt)
+	if runtimeErr, ok := err.(*errors.RuntimeError); ok && runtimeErr != i.raised {
+		// The error fails every statement it unwinds; only the first
+		// one reports it.
+		i.raised = runtimeErr
+		observer.Error(runtimeErr)
+	}
+	return ret, err
+}
+func (i *Interpreter) observeExpression(expr ast.Expr) (any, error) {
+	observer := i.observer
+	value, err := i.evalExpr(expr)
+	observer.Expression(expr, value, err)
+	return value, err
+}
+
+// defined tells the observer, if any, that a declaration bound name to
+// value in the current environment.
+func (i *Interpreter) defined(name token.Token, value any) {
+	if i.observer != nil {
+		i.observer.Define(name, value, i.env)
+	}
+}
+
+// assigned tells the observer, if any, that an assignment in env stored
+// value in the variable name.
+func (i *Interpreter) assigned(name token.Token, value any, env *Environment) {
+	if i.observer != nil {
+		i.observer.Assign(name, value, env)
+	}
+}
+
+// branched tells the observer, if any, which way the condition of node
+// went.
+func (i *Interpreter) branched(node any, taken bool) {
+	if i.observer != nil {
+		i.observer.Branch(node, taken)
+	}
+}
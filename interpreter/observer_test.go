@@ -0,0 +1,102 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"lox/ast"
+	"lox/errors"
+	"lox/token"
+	"strings"
+	"testing"
+)
+
+// recorder logs what it observes, except expressions that succeed, which
+// it only counts.
+type recorder struct {
+	log         []string
+	expressions int
+}
+
+func (r *recorder) Statement(stmt ast.Stmt, env *Environment) error {
+	r.log = append(r.log, fmt.Sprintf("stmt %d", ast.Line(stmt)))
+	return nil
+}
+func (r *recorder) Expression(expr ast.Expr, value any, err error) {
+	if err != nil {
+		r.log = append(r.log, fmt.Sprintf("fail %d", ast.Line(expr)))
+	}
+	r.expressions++
+}
+func (r *recorder) Call(fn *Function, env *Environment) {
+	r.log = append(r.log, "call "+fn.Name())
+}
+func (r *recorder) Return(fn *Function, value any, err error) {
+	r.log = append(r.log, fmt.Sprintf("return %s %s", fn.Name(), Repr(value)))
+}
+func (r *recorder) Branch(node any, taken bool) {
+	r.log = append(r.log, fmt.Sprintf("branch %d %t", ast.Line(node), taken))
+}
+func (r *recorder) Define(name token.Token, value any, env *Environment) {
+	r.log = append(r.log, fmt.Sprintf("define %s %s", name.Lexeme, Repr(value)))
+}
+func (r *recorder) Assign(name token.Token, value any, env *Environment) {
+	r.log = append(r.log, fmt.Sprintf("assign %s %s", name.Lexeme, Repr(value)))
+}
+func (r *recorder) Error(err *errors.RuntimeError) {
+	r.log = append(r.log, "error "+err.Msg)
+}
+
+func TestObserver(t *testing.T) {
+	interp := NewInterpreter(NewEnvironment(nil))
+	interp.SetOutput(io.Discard)
+	r := &recorder{}
+	interp.SetObserver(r)
+	_, err := evalSource(t, interp, `var a = 1;
+fun f(x) { a = a + x; return a; }
+f(2);
+a += a > 2 ? 1 : f(100);
+print -f(nil);`)
+	if err == nil {
+		t.Fatal("expected a runtime error")
+	}
+	expected := []string{
+		"stmt 1", "define a 1",
+		"stmt 2", "define f <fn f>",
+		"stmt 3", "call f", "stmt 2", "assign a 3", "stmt 2", "return f 3",
+		"stmt 4", "branch 4 true", "assign a 4",
+		"stmt 5", "call f", "stmt 2", "fail 2", "fail 2",
+		"error Operands must be numbers or strings.",
+		"return f nil", "fail 5", "fail 5",
+	}
+	if got := strings.Join(r.log, "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), got)
+	}
+	if r.expressions == 0 {
+		t.Error("expected expressions to be observed")
+	}
+
+	interp.SetObserver(nil)
+	r.log = nil
+	if _, err := evalSource(t, interp, "a = 5;"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.log) != 0 {
+		t.Errorf("expected a detached observer to see nothing, got %v", r.log)
+	}
+}
+
+func TestObservers(t *testing.T) {
+	interp := NewInterpreter(NewEnvironment(nil))
+	interp.SetOutput(io.Discard)
+	a, b := &recorder{}, &recorder{}
+	interp.SetObserver(Observers{a, b})
+	if _, err := evalSource(t, interp, "fun f() { return 1; }\nf();"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "stmt 1\ndefine f <fn f>\nstmt 2\ncall f\nstmt 1\nreturn f 1"
+	for _, r := range []*recorder{a, b} {
+		if got := strings.Join(r.log, "\n"); got != expected {
+			t.Errorf("expected\n%s\ngot\n%s", expected, got)
+		}
+	}
+}
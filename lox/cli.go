@@ -0,0 +1,549 @@
+package lox
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"lox/ast"
+	"lox/coverage"
+	"lox/dap"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/lsp"
+	"lox/profiler"
+	"lox/scanner"
+	"lox/token"
+	"os"
+	"strings"
+)
+
+// Version is printed by "lox version". Release builds set it with
+// -ldflags "-X lox/lox.Version=...".
+var Version = "0.1.0"
+
+// Exit codes follow the reference implementation, which takes them from
+// BSD's sysexits.h.
+const (
+	ExitUsage        = 64
+	ExitCompileError = 65
+	ExitNoInput      = 66
+	ExitRuntimeError = 70
+)
+
+const usage = `Usage: lox <command> [arguments]
+
+Commands:
+  run [flags] <file> [args...]    run a script; -cover or -profile
+  repl [flags]                    start an interactive prompt
+  debug [flags] <file> [args...]  run a script in the debugger
+  check <files...>                report compile errors without running
+  tokens <file>                   print the tokens of a script
+  ast <file>                      print the syntax tree of a script
+  fmt [flags] <files...>          format scripts; -w, -check or -diff
+  test [flags] [paths...]         check scripts against their expectations
+  lsp                             start a language server on stdin and stdout
+  dap [flags]                     start a debug adapter on stdin and stdout
+  version                         print the version
+
+A file named "-" is read from standard input. "lox <file>" is short for
+"lox run <file>" and "lox" alone starts the prompt.
+`
+
+// CLI runs the lox command line with the given standard streams.
+type CLI struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Main runs the command named by args, which exclude the program name,
+// and returns the process exit code.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &CLI{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		return c.repl(nil)
+	}
+	switch args[0] {
+	case "run":
+		return c.run(args[1:])
+	case "repl":
+		return c.repl(args[1:])
+	case "debug":
+		return c.debug(args[1:])
+	case "check":
+		return c.check(args[1:])
+	case "tokens":
+		return c.tokens(args[1:])
+	case "ast":
+		return c.ast(args[1:])
+	case "fmt":
+		return c.fmt(args[1:])
+	case "test":
+		return c.test(args[1:])
+	case "lsp":
+		return c.lsp(args[1:])
+	case "dap":
+		return c.dap(args[1:])
+	case "version":
+		fmt.Fprintf(stdout, "lox %s\n", Version)
+		return 0
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	if args[0] == "-" || strings.HasSuffix(args[0], ".lox") {
+		return c.run(args)
+	}
+	fmt.Fprintf(stderr, "lox: unknown command %q\n\n%s", args[0], usage)
+	return ExitUsage
+}
+
+// flags returns an empty flag set for the named command.
+func (c *CLI) flags(name string) *flag.FlagSet {
+	set := flag.NewFlagSet("lox "+name, flag.ContinueOnError)
+	set.SetOutput(c.stderr)
+	return set
+}
+
+// capabilityFlags adds -allow-fs and -allow-os to set. The returned
+// function reads them once set has been parsed.
+func capabilityFlags(set *flag.FlagSet) func() interpreter.Capability {
+	allowFS := set.Bool("allow-fs", false, "allow scripts to read and write files")
+	allowOS := set.Bool("allow-os", false, "allow scripts to read the environment and arguments and to exit")
+	return func() interpreter.Capability {
+		var caps interpreter.Capability
+		if *allowFS {
+			caps |= interpreter.CapFS
+		}
+		if *allowOS {
+			caps |= interpreter.CapOS
+		}
+		return caps
+	}
+}
+
+// parse parses the flags of a command and returns the exit code to stop
+// with, or -1 to carry on.
+func parse(set *flag.FlagSet, args []string) int {
+	err := set.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	return -1
+}
+func (c *CLI) run(args []string) int {
+	set := c.flags("run")
+	caps := capabilityFlags(set)
+	cover := coverFlags(set)
+	prof := profileFlags(set)
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	if set.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: lox run [flags] <file> [args...]")
+		return ExitUsage
+	}
+	lox := c.newLox(set.Arg(0))
+	lox.Grant(caps())
+	lox.SetArgs(set.Args()[1:])
+	profile := cover.newProfile()
+	if profile != nil {
+		lox.Cover(profile)
+	}
+	timing := prof.newProfile()
+	if timing != nil {
+		lox.Profile(timing)
+	}
+	code := c.report(lox.RunFile())
+	// The script's output is on stdout, so the summaries go to stderr.
+	code = max(code, c.writeCoverage(cover, profile, c.stderr))
+	return max(code, c.writeProfile(prof, timing, c.stderr))
+}
+
+// coverOptions are the coverage flags of run and test.
+type coverOptions struct {
+	summary bool
+	profile string
+	html    string
+}
+
+// coverFlags adds -cover, -coverprofile and -coverhtml to set. The last
+// two imply the first.
+func coverFlags(set *flag.FlagSet) *coverOptions {
+	o := &coverOptions{}
+	set.BoolVar(&o.summary, "cover", false, "report the lines and branches that ran")
+	set.StringVar(&o.profile, "coverprofile", "", "write a coverage profile to `file`")
+	set.StringVar(&o.html, "coverhtml", "", "write an annotated HTML coverage report to `file`")
+	return o
+}
+
+// newProfile returns a profile to record coverage in, or nil when it is
+// off.
+func (o *coverOptions) newProfile() *coverage.Profile {
+	if !o.summary && o.profile == "" && o.html == "" {
+		return nil
+	}
+	return coverage.NewProfile()
+}
+
+// writeCoverage writes the summary of profile to w and the files asked
+// for by o. It returns 1 if one of them could not be written.
+func (c *CLI) writeCoverage(o *coverOptions, profile *coverage.Profile, w io.Writer) int {
+	if profile == nil {
+		return 0
+	}
+	profile.WriteSummary(w)
+	return c.writeFiles(output{o.profile, profile.WriteProfile}, output{o.html, profile.WriteHTML})
+}
+
+// profileOptions are the profiling flags of run.
+type profileOptions struct {
+	pprof  string
+	folded string
+}
+
+// profileFlags adds -profile and -profilefolded to set.
+func profileFlags(set *flag.FlagSet) *profileOptions {
+	o := &profileOptions{}
+	set.StringVar(&o.pprof, "profile", "", "write a pprof profile of the time and calls of each function and line to `file`")
+	set.StringVar(&o.folded, "profilefolded", "", "write the time of each call stack to `file` as folded stacks for flame graphs")
+	return o
+}
+
+// newProfile returns a profile to measure the script in, or nil when
+// profiling is off.
+func (o *profileOptions) newProfile() *profiler.Profile {
+	if o.pprof == "" && o.folded == "" {
+		return nil
+	}
+	return profiler.NewProfile()
+}
+
+// writeProfile writes the summary of profile to w and the files asked for
+// by o. It returns 1 if one of them could not be written.
+func (c *CLI) writeProfile(o *profileOptions, profile *profiler.Profile, w io.Writer) int {
+	if profile == nil {
+		return 0
+	}
+	profile.WriteSummary(w)
+	return c.writeFiles(output{o.pprof, profile.WriteProfile}, output{o.folded, profile.WriteFolded})
+}
+
+// output is a file to write with write, or none if path is "".
+type output struct {
+	path  string
+	write func(io.Writer) error
+}
+
+// writeFiles writes outputs. It returns 1 if one of them could not be
+// written.
+func (c *CLI) writeFiles(outputs ...output) int {
+	code := 0
+	for _, out := range outputs {
+		if out.path == "" {
+			continue
+		}
+		if err := writeFile(out.path, out.write); err != nil {
+			fmt.Fprintf(c.stderr, "lox: %s\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// writeFile creates the file at path and writes it with write.
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+func (c *CLI) repl(args []string) int {
+	set := c.flags("repl")
+	caps := capabilityFlags(set)
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	lox := c.newLox("")
+	lox.Grant(caps())
+	return c.report(lox.RunPrompt())
+}
+
+// debug runs "lox debug", which runs a script like run but under the
+// debugger. Its commands are read from standard input, so the script
+// cannot be.
+func (c *CLI) debug(args []string) int {
+	set := c.flags("debug")
+	caps := capabilityFlags(set)
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	if set.NArg() == 0 || set.Arg(0) == "-" {
+		fmt.Fprintln(c.stderr, "usage: lox debug [flags] <file> [args...]")
+		return ExitUsage
+	}
+	lox := c.newLox(set.Arg(0))
+	lox.Grant(caps())
+	lox.SetArgs(set.Args()[1:])
+	return c.report(lox.Debug())
+}
+func (c *CLI) newLox(script string) *Lox {
+	lox := NewLox(script)
+	lox.SetStdio(c.stdin, c.stdout, c.stderr)
+	return lox
+}
+
+// report prints err and returns the exit code for it.
+func (c *CLI) report(err error) int {
+	var exit *interpreter.Exit
+	var compileErr *CompileError
+	var runtimeErr *er.RuntimeError
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exit):
+		return exit.Code
+	case errors.As(err, &compileErr):
+		fmt.Fprintln(c.stderr, compileErr)
+		return ExitCompileError
+	case errors.As(err, &runtimeErr):
+		fmt.Fprintln(c.stderr, runtimeErr)
+		return ExitRuntimeError
+	case errors.As(err, &pathErr):
+		fmt.Fprintf(c.stderr, "lox: %s\n", err)
+		return ExitNoInput
+	default:
+		fmt.Fprintf(c.stderr, "lox: %s\n", err)
+		return ExitRuntimeError
+	}
+}
+
+// reportIn reports an error in the file at path and returns the exit code
+// for it. Compile errors are prefixed with the path.
+func (c *CLI) reportIn(path string, err error) int {
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) {
+		fmt.Fprintf(c.stderr, "%s: %s\n", path, err)
+		return ExitCompileError
+	}
+	for _, err := range compileErr.Errors {
+		fmt.Fprintf(c.stderr, "%s: %s\n", path, err)
+	}
+	return ExitCompileError
+}
+
+// files parses the flags of a command that reads scripts and returns
+// their paths, at least one of them.
+func (c *CLI) files(name string, args []string) ([]string, int) {
+	set := c.flags(name)
+	if code := parse(set, args); code >= 0 {
+		return nil, code
+	}
+	if set.NArg() == 0 {
+		fmt.Fprintf(c.stderr, "usage: lox %s <files...>\n", name)
+		return nil, ExitUsage
+	}
+	return set.Args(), -1
+}
+
+// compileFiles reads and compiles each file and passes the result to fn.
+// Compile errors are reported with the file they belong to.
+func (c *CLI) compileFiles(paths []string, fn func(path string, stmts []ast.Stmt)) int {
+	code := 0
+	for _, path := range paths {
+		source, err := readSource(path, c.stdin)
+		if err != nil {
+			code = max(code, c.report(err))
+			continue
+		}
+		stmts, err := compile(source)
+		if err != nil {
+			code = max(code, c.reportIn(path, err))
+			continue
+		}
+		fn(path, stmts)
+	}
+	return code
+}
+func (c *CLI) check(args []string) int {
+	paths, code := c.files("check", args)
+	if code >= 0 {
+		return code
+	}
+	return c.compileFiles(paths, func(string, []ast.Stmt) {})
+}
+func (c *CLI) tokens(args []string) int {
+	paths, code := c.files("tokens", args)
+	if code >= 0 {
+		return code
+	}
+	code = 0
+	for _, path := range paths {
+		source, err := readSource(path, c.stdin)
+		if err != nil {
+			code = max(code, c.report(err))
+			continue
+		}
+		er.Errors = er.Errors[:0]
+		writeTokens(c.stdout, scanner.NewSacnner(source).ScanTokens())
+		for _, err := range er.Errors {
+			fmt.Fprintf(c.stderr, "%s: %s\n", path, err)
+			code = max(code, ExitCompileError)
+		}
+		er.Errors = er.Errors[:0]
+	}
+	return code
+}
+
+// writeTokens prints one token per line with its line, type, lexeme and
+// literal value.
+func writeTokens(w io.Writer, tokens []*token.Token) {
+	for _, tok := range tokens {
+		if tok.Literal != nil {
+			fmt.Fprintf(w, "%d %s %s %v\n", tok.Line, tok.Typ, tok.Lexeme, tok.Literal)
+		} else {
+			fmt.Fprintf(w, "%d %s %s\n", tok.Line, tok.Typ, tok.Lexeme)
+		}
+	}
+}
+func (c *CLI) ast(args []string) int {
+	paths, code := c.files("ast", args)
+	if code >= 0 {
+		return code
+	}
+	return c.compileFiles(paths, func(_ string, stmts []ast.Stmt) {
+		for _, stmt := range stmts {
+			fmt.Fprintln(c.stdout, ast.StmtPrinter(stmt))
+		}
+	})
+}
+
+// fmt prints scripts in the standard layout, or with -w rewrites them.
+// With -check it lists the scripts that are not formatted and with -diff
+// shows how they would change; both then exit with 1.
+func (c *CLI) fmt(args []string) int {
+	set := c.flags("fmt")
+	write := set.Bool("w", false, "write the result to the file instead of stdout")
+	check := set.Bool("check", false, "list files whose formatting differs")
+	diff := set.Bool("diff", false, "print the changes formatting would make")
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	if set.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: lox fmt [-w | -check | -diff] <files...>")
+		return ExitUsage
+	}
+	code := 0
+	for _, path := range set.Args() {
+		source, err := readSource(path, c.stdin)
+		if err != nil {
+			code = max(code, c.report(err))
+			continue
+		}
+		formatted, err := formatSource(source)
+		if err != nil {
+			code = max(code, c.reportIn(path, err))
+			continue
+		}
+		switch {
+		case *check || *diff:
+			if formatted == source {
+				continue
+			}
+			code = max(code, 1)
+			if *check {
+				fmt.Fprintln(c.stdout, path)
+			}
+			if *diff {
+				fmt.Fprint(c.stdout, unifiedDiff(path, source, formatted))
+			}
+		case *write && path != "-":
+			if formatted != source {
+				if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+					code = max(code, c.report(err))
+				}
+			}
+		default:
+			fmt.Fprint(c.stdout, formatted)
+		}
+	}
+	return code
+}
+
+// test runs "lox test [paths...]", checking the scripts below each path,
+// or below tests when none are given, against their expectation comments.
+func (c *CLI) test(args []string) int {
+	set := c.flags("test")
+	caps := capabilityFlags(set)
+	cover := coverFlags(set)
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	paths := set.Args()
+	if len(paths) == 0 {
+		paths = []string{"tests"}
+	}
+	profile := cover.newProfile()
+	_, failed, err := RunTests(paths, c.stdout, caps(), profile)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "lox: %s\n", err)
+		return 1
+	}
+	code := c.writeCoverage(cover, profile, c.stdout)
+	if failed > 0 {
+		return 1
+	}
+	return code
+}
+
+// lsp runs "lox lsp", serving the Language Server Protocol on the standard
+// streams until the client exits.
+func (c *CLI) lsp(args []string) int {
+	set := c.flags("lsp")
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	if err := lsp.NewServer(c.stdin, c.stdout).Run(); err != nil {
+		fmt.Fprintf(c.stderr, "lox: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// dap runs "lox dap", serving the Debug Adapter Protocol on the standard
+// streams until the client disconnects. The flags grant capabilities to
+// the programs it launches.
+func (c *CLI) dap(args []string) int {
+	set := c.flags("dap")
+	caps := capabilityFlags(set)
+	if code := parse(set, args); code >= 0 {
+		return code
+	}
+	granted := caps()
+	launch := func(path string, args []string, out io.Writer) (*interpreter.Interpreter, []ast.Stmt, error) {
+		lox := NewLox(path)
+		// The standard streams carry the protocol, so the program reads
+		// nothing and its output is sent to the client.
+		lox.SetStdio(strings.NewReader(""), out, out)
+		lox.Grant(granted)
+		lox.SetArgs(args)
+		_, stmts, err := lox.compileScript()
+		return lox.executor, stmts, err
+	}
+	if err := dap.NewServer(c.stdin, c.stdout, launch).Run(); err != nil {
+		fmt.Fprintf(c.stderr, "lox: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package lox
+
+import (
+	"lox/ast"
+	"lox/scanner"
+	"lox/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{args: []string{"version"}, stdout: "lox " + Version + "\n"},
+		{args: []string{"run", "-"}, stdin: `print 1 + 2;`, stdout: "3\n"},
+		{args: []string{"-"}, stdin: `print "short";`, stdout: "short\n"},
+		{args: []string{"run", "-", "a", "b"}, stdin: `print 1;`, stdout: "1\n"},
+		{args: []string{"run", "-allow-os", "-", "a", "b"}, stdin: `print args();`, stdout: "[\"a\", \"b\"]\n"},
+		{args: []string{"run", "-allow-os", "-"}, stdin: `exit(3);`, code: 3},
+		{args: []string{"run", "-allow-fs", "-"}, stdin: `import "../tests/modules/money.lox" as money; print money.format(money.withTax(100));`, stdout: "125 EUR\n"},
+		{args: []string{"run", "-"}, stdin: `import "../tests/modules/money.lox" as money;`, code: ExitRuntimeError, stderr: "Cannot import '../tests/modules/money.lox': filesystem access is not enabled."},
+		{args: []string{"run", "-"}, stdin: `print 1 +;`, code: ExitCompileError, stderr: "[line 1] Error at ';': Expect expression."},
+		{args: []string{"run", "-"}, stdin: "print 1;\nprint -\"a\";", code: ExitRuntimeError, stdout: "1\n", stderr: "Operand must be a number.\n[line 2]\n"},
+		{args: []string{"run", "missing.lox"}, code: ExitNoInput, stderr: "missing.lox"},
+		{args: []string{"run"}, code: ExitUsage},
+		{args: []string{"run", "-bogus", "-"}, code: ExitUsage},
+		{args: []string{"frobnicate"}, code: ExitUsage, stderr: "unknown command"},
+		{args: []string{"check", "-"}, stdin: `print 1;`},
+		{args: []string{"check", "-"}, stdin: `const a;`, code: ExitCompileError, stderr: "-: [line 1] Error at 'a': Const declaration must be initialized.\n"},
+		{args: []string{"check", "-"}, stdin: `print -"a";`},
+		{args: []string{"tokens", "-"}, stdin: `var a = "x";`, stdout: "1 VAR var\n1 IDENTIFIER a\n1 EQUAL =\n1 STRING \"x\" x\n1 SEMICOLON ;\n1 EOF \n"},
+		{args: []string{"tokens", "-"}, stdin: `@`, code: ExitCompileError, stdout: "1 EOF \n", stderr: "Unexpected character."},
+		{args: []string{"ast", "-"}, stdin: `var a = 1 + 2 * 3; if (a > 1) print a; else { a++; }`, stdout: "(var a (+ 1 (* 2 3)))\n(if (> a 1) (print a) (block (; (++post a))))\n"},
+		{args: []string{"fmt", "-"}, stdin: `fun f(a){if(a)return 1;else return 2;} print f(true);`, stdout: "fun f(a) {\n  if (a) return 1;\n  else return 2;\n}\nprint f(true);\n"},
+		{args: []string{"fmt", "-check", "-"}, stdin: "print  1;", code: 1, stdout: "-\n"},
+		{args: []string{"fmt", "-check", "-"}, stdin: "print 1;\n"},
+		{args: []string{"fmt", "-diff", "-"}, stdin: "var a=1;\nprint a;\n", code: 1, stdout: "--- -\n+++ - (formatted)\n@@ -1,2 +1,2 @@\n-var a=1;\n+var a = 1;\n print a;\n"},
+		{args: []string{"fmt", "-"}, stdin: "print 1 +;", code: ExitCompileError, stderr: "-: [line 1] Error at ';'"},
+		{args: []string{"lsp"}, stdin: "Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}", code: 1, stderr: "exit without shutdown"},
+		{args: []string{"dap"}, stdin: "Content-Length: 83\r\n\r\n{\"seq\":1,\"type\":\"request\",\"command\":\"launch\",\"arguments\":{\"program\":\"missing.lox\"}}", stdout: "Content-Length: 134\r\n\r\n{\"seq\":1,\"type\":\"response\",\"request_seq\":1,\"success\":false,\"command\":\"launch\",\"message\":\"open missing.lox: no such file or directory\"}"},
+		{args: []string{"run", "-cover", "-"}, stdin: "var a = 1;\nif (a > 1) print a;\nelse print -a;\n", stdout: "-1\n", stderr: "<stdin>: 100.0% of lines (3/3), 50.0% of branches (1/2)"},
+		{args: []string{"run", "-profilefolded", os.DevNull, "-"}, stdin: "fun f() {\n  return 1;\n}\nprint f();\n", stdout: "1\n", stderr: "  f (<stdin>:1)\n"},
+		{args: []string{"run", "-profile", filepath.Join("missing", "cpu.pb.gz"), "-"}, stdin: "print 1;", code: 1, stdout: "1\n", stderr: "lox: open missing"},
+		{args: []string{"repl"}, stdin: "var a = 1;\nprint a +;\nprint a;\n", stdout: "> > > 1\n> \n", stderr: "Expect expression."},
+	}
+	for _, test := range tests {
+		var stdout, stderr strings.Builder
+		code := Main(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d (%s)", test.args, test.code, code, stderr.String())
+		}
+		if stdout.String() != test.stdout {
+			t.Errorf("%v: expected output %q, got %q", test.args, test.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("%v: expected errors to contain %q, got %q", test.args, test.stderr, stderr.String())
+		}
+	}
+}
+
+// TestFormat checks that formatting the test scripts keeps their meaning
+// and comments, and that formatting the result again changes nothing.
+func TestFormat(t *testing.T) {
+	paths, err := filepath.Glob("../tests/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts, err := compile(string(bs))
+		if err != nil {
+			continue
+		}
+		formatted, err := formatSource(string(bs))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		again, err := compile(formatted)
+		if err != nil {
+			t.Errorf("%s: formatted source does not compile: %v\n%s", path, err, formatted)
+			continue
+		}
+		if twice, _ := formatSource(formatted); twice != formatted {
+			t.Errorf("%s: formatting is not idempotent", path)
+		}
+		if printTree(again) != printTree(stmts) {
+			t.Errorf("%s: formatting changed the syntax tree", path)
+		}
+		if countComments(formatted) != countComments(string(bs)) {
+			t.Errorf("%s: formatting lost comments", path)
+		}
+	}
+}
+func printTree(stmts []ast.Stmt) string {
+	var sb strings.Builder
+	for _, stmt := range stmts {
+		sb.WriteString(ast.StmtPrinter(stmt))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+func countComments(source string) int {
+	count := 0
+	for _, tok := range scanner.NewSacnner(source).KeepComments().ScanTokens() {
+		if tok.Typ == token.COMMENT {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDebug(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.lox")
+	if err := os.WriteFile(path, []byte("fun f(a) {\n  return a * 2;\n}\nprint f(21);\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr strings.Builder
+	stdin := "break 2\ncontinue\nbacktrace\nvars\nprint a + 1\nprint\nwatch\nprint a // hi\nnext\nquit\n"
+	code := Main([]string{"debug", path}, strings.NewReader(stdin), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (%s)", code, stderr.String())
+	}
+	for _, want := range []string{
+		"main.lox:1\tfun f(a) {",
+		"Breakpoint at " + path + ":2",
+		"* 0 f at " + path + ":2\n  1 <script> at " + path + ":4\n",
+		"scope 0:\n  a = 21\nglobals:\n  f = <fn f>\n",
+		"(debug) 22\n",
+		"(debug) 42\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "Usage: print <expr>.\nUsage: watch <expr>.\n") {
+		t.Errorf("expected usage errors for empty expressions, got\n%s", stderr.String())
+	}
+	stdout.Reset()
+	if code := Main([]string{"debug", path}, strings.NewReader("quit\n"), &stdout, &stderr); code != 0 || strings.Contains(stdout.String(), "42\n") {
+		t.Errorf("expected quit to stop the program, got code %d and\n%s", code, stdout.String())
+	}
+}
@@ -0,0 +1,259 @@
+package lox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"lox/debugger"
+	er "lox/errors"
+	"lox/interpreter"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const debugPrompt = "(debug) "
+
+const debugHelp = `Commands:
+  break [file:]<line>  stop at a line, of the current file by default (b)
+  clear [file:]<line>  remove a breakpoint
+  step                 run to the next line, into calls (s)
+  next                 run to the next line of this function (n)
+  out                  run until this function returns (o)
+  continue             run to the next breakpoint (c)
+  backtrace            show the call stack (bt)
+  frame <n>            select a frame of the call stack (f)
+  vars                 show the variables of the selected frame (v)
+  print <expr>         evaluate an expression in the selected frame (p)
+  watch <expr>         evaluate an expression at every stop (w)
+  unwatch <n>          remove a watch expression
+  list                 show the source around the current line (l)
+  quit                 stop the program (q)
+`
+
+// Debug runs the script under the debugger, stopped before its first
+// statement, with commands read from standard input.
+func (l *Lox) Debug() error {
+	source, err := readSource(l.script, l.stdin)
+	if err != nil {
+		return err
+	}
+	if l.script != "-" {
+		l.executor.SetFile(l.script)
+	}
+	stmts, err := compile(source)
+	if err != nil {
+		return err
+	}
+	l.builtins = l.bindings()
+	prompt := &debugShell{
+		lox:     l,
+		reader:  bufio.NewReader(l.stdin),
+		sources: map[string][]string{l.executor.File(): splitLines(source)},
+	}
+	prompt.debugger = debugger.New(l.executor, prompt)
+	l.executor.SetObserver(prompt.debugger)
+	defer l.executor.SetObserver(nil)
+	_, err = l.executor.Run(stmts)
+	if errors.Is(err, debugger.ErrQuit) {
+		return nil
+	}
+	return err
+}
+
+// debugShell is the command line front end of the debugger.
+type debugShell struct {
+	lox      *Lox
+	debugger *debugger.Debugger
+	reader   *bufio.Reader
+	// sources holds the lines of each file shown so far, by key.
+	sources map[string][]string
+	watches []string
+	// frame is the selected frame, an index in the call stack.
+	frame int
+}
+
+// Stopped reads commands until one of them resumes the program.
+func (s *debugShell) Stopped(reason string) debugger.Action {
+	frames := s.debugger.Frames()
+	s.frame = len(frames) - 1
+	top := frames[s.frame]
+	out := s.lox.stdout
+	if reason == debugger.ReasonBreakpoint {
+		fmt.Fprintf(out, "Breakpoint at %s\n", s.where(top))
+	}
+	s.showLine(top)
+	for i, watch := range s.watches {
+		fmt.Fprintf(out, "%d: %s = %s\n", i+1, watch, s.evaluate(watch))
+	}
+	for {
+		fmt.Fprint(out, debugPrompt)
+		line, err := s.reader.ReadString('\n')
+		if err != nil && line == "" {
+			if err != io.EOF {
+				fmt.Fprintln(s.lox.stderr, err)
+			}
+			fmt.Fprintln(out)
+			return debugger.Quit
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "s", "step":
+			return debugger.StepIn
+		case "n", "next":
+			return debugger.StepOver
+		case "o", "out":
+			return debugger.StepOut
+		case "c", "continue":
+			return debugger.Continue
+		case "q", "quit":
+			return debugger.Quit
+		case "b", "break", "clear":
+			s.breakpoint(arg, name != "clear")
+		case "bt", "backtrace":
+			frames := s.debugger.Frames()
+			for i := len(frames) - 1; i >= 0; i-- {
+				marker := " "
+				if i == s.frame {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "%s %d %s at %s\n", marker, len(frames)-1-i, frames[i].Name, s.where(frames[i]))
+			}
+		case "f", "frame":
+			frames := s.debugger.Frames()
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(frames) {
+				fmt.Fprintf(s.lox.stderr, "No frame '%s'.\n", arg)
+				continue
+			}
+			s.frame = len(frames) - 1 - n
+			s.showLine(frames[s.frame])
+		case "v", "vars":
+			s.vars()
+		case "p", "print":
+			if arg == "" {
+				fmt.Fprintln(s.lox.stderr, "Usage: print <expr>.")
+				continue
+			}
+			fmt.Fprintln(out, s.evaluate(arg))
+		case "w", "watch":
+			if arg == "" {
+				fmt.Fprintln(s.lox.stderr, "Usage: watch <expr>.")
+				continue
+			}
+			s.watches = append(s.watches, arg)
+			fmt.Fprintf(out, "%d: %s = %s\n", len(s.watches), arg, s.evaluate(arg))
+		case "unwatch":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(s.watches) {
+				fmt.Fprintf(s.lox.stderr, "No watch '%s'.\n", arg)
+				continue
+			}
+			s.watches = append(s.watches[:n-1], s.watches[n:]...)
+		case "l", "list":
+			s.list(s.debugger.Frames()[s.frame])
+		case "h", "help":
+			fmt.Fprint(out, debugHelp)
+		case "":
+		default:
+			fmt.Fprintf(s.lox.stderr, "Unknown command '%s'. Type help for a list.\n", name)
+		}
+	}
+}
+
+// breakpoint sets or clears the breakpoint at "[file:]line".
+func (s *debugShell) breakpoint(arg string, on bool) {
+	file := s.debugger.Frames()[s.frame].File
+	if path, line, ok := strings.Cut(arg, ":"); ok {
+		key, err := s.lox.executor.FileKey(path)
+		if err != nil {
+			fmt.Fprintln(s.lox.stderr, err)
+			return
+		}
+		file, arg = key, line
+	}
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(s.lox.stderr, "Invalid line '%s'.\n", arg)
+		return
+	}
+	s.debugger.SetBreakpoint(file, line, on)
+	if on {
+		fmt.Fprintf(s.lox.stdout, "Breakpoint set at %s:%d\n", interpreter.DisplayName(file), line)
+	}
+}
+
+// vars prints the variables of the selected frame, innermost scope first,
+// down to the globals the script defined.
+func (s *debugShell) vars() {
+	out := s.lox.stdout
+	globals := s.lox.executor.Globals()
+	depth := 0
+	for env := s.debugger.Frames()[s.frame].Env; env != nil; env = env.Enclosing() {
+		if env == globals {
+			fmt.Fprintln(out, "globals:")
+		} else {
+			fmt.Fprintf(out, "scope %d:\n", depth)
+		}
+		for _, name := range env.Names() {
+			value, _ := env.Lookup(name)
+			if builtin, ok := s.lox.builtins[name]; ok && env == globals && builtin == value {
+				continue
+			}
+			fmt.Fprintf(out, "  %s = %s\n", name, interpreter.Repr(value))
+		}
+		depth++
+	}
+}
+func (s *debugShell) evaluate(source string) string {
+	value, err := s.debugger.Evaluate(source, s.frame)
+	var runtimeErr *er.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return "error: " + runtimeErr.Msg
+	}
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return interpreter.Repr(value)
+}
+func (s *debugShell) where(frame *debugger.Frame) string {
+	return fmt.Sprintf("%s:%d", interpreter.DisplayName(frame.File), frame.Line)
+}
+
+// showLine prints the line where frame stopped.
+func (s *debugShell) showLine(frame *debugger.Frame) {
+	lines := s.source(frame.File)
+	text := ""
+	if frame.Line >= 1 && frame.Line <= len(lines) {
+		text = lines[frame.Line-1]
+	}
+	fmt.Fprintf(s.lox.stdout, "%s\t%s\n", s.where(frame), text)
+}
+
+// list prints the lines around the one where frame stopped.
+func (s *debugShell) list(frame *debugger.Frame) {
+	lines := s.source(frame.File)
+	for n := max(frame.Line-5, 1); n <= min(frame.Line+5, len(lines)); n++ {
+		marker := "  "
+		switch {
+		case n == frame.Line:
+			marker = "->"
+		case s.debugger.Breakpoint(frame.File, n):
+			marker = "b "
+		}
+		fmt.Fprintf(s.lox.stdout, "%s %4d  %s\n", marker, n, lines[n-1])
+	}
+}
+func (s *debugShell) source(file string) []string {
+	if lines, ok := s.sources[file]; ok {
+		return lines
+	}
+	bs, _ := os.ReadFile(file)
+	s.sources[file] = splitLines(string(bs))
+	return s.sources[file]
+}
+func splitLines(source string) []string {
+	return strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+}
@@ -0,0 +1,319 @@
+package lox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt is returned by ReadLine when the user presses Ctrl-C.
+var errInterrupt = errors.New("interrupt")
+
+// lineReader reads the lines typed at the prompt.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close() error
+}
+
+// newLineReader returns a line editor when both streams are terminals and
+// otherwise a reader of plain lines, as when input is piped in.
+func newLineReader(stdin io.Reader, stdout io.Writer, complete completer) lineReader {
+	in, inOK := stdin.(*os.File)
+	out, outOK := stdout.(*os.File)
+	if inOK && outOK && isTerminal(in.Fd()) && isTerminal(out.Fd()) {
+		return &editor{
+			raw:      func() (func(), error) { return makeRaw(in.Fd()) },
+			reader:   bufio.NewReader(in),
+			out:      out,
+			history:  loadHistory(historyPath()),
+			complete: complete,
+		}
+	}
+	return &plainReader{reader: bufio.NewReader(stdin), out: stdout}
+}
+
+type plainReader struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.reader.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+func (r *plainReader) Close() error {
+	return nil
+}
+
+// completer returns the candidates for completing the word that ends at
+// pos in line, and where that word starts.
+type completer func(line []rune, pos int) (start int, candidates []string)
+
+// editor reads lines from a terminal in raw mode, with cursor movement,
+// history and completion. The terminal is only raw while a line is read.
+type editor struct {
+	// raw switches the terminal to raw mode and returns a function that
+	// switches it back.
+	raw      func() (func(), error)
+	reader   *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete completer
+
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	restore, err := e.raw()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.history.rewind()
+	e.refresh()
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.buf)
+			e.history.add(line)
+			return line, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			e.delete()
+		case 127, 8: // Backspace
+			if e.pos > 0 {
+				e.pos--
+				e.delete()
+			}
+		case 1: // Ctrl-A
+			e.pos = 0
+		case 5: // Ctrl-E
+			e.pos = len(e.buf)
+		case 2: // Ctrl-B
+			e.pos = max(e.pos-1, 0)
+		case 6: // Ctrl-F
+			e.pos = min(e.pos+1, len(e.buf))
+		case 11: // Ctrl-K
+			e.buf = e.buf[:e.pos]
+		case 21: // Ctrl-U
+			e.buf = e.buf[e.pos:]
+			e.pos = 0
+		case 23: // Ctrl-W
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			e.recall(e.history.previous)
+		case 14: // Ctrl-N
+			e.recall(e.history.next)
+		case '\t':
+			e.completeWord()
+		case 27:
+			e.escape()
+		default:
+			if unicode.IsPrint(r) {
+				e.buf = append(e.buf[:e.pos], append([]rune{r}, e.buf[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.refresh()
+	}
+}
+
+// escape handles the escape sequences sent by arrow, Home, End and Delete
+// keys.
+func (e *editor) escape() {
+	if r, _, _ := e.reader.ReadRune(); r != '[' && r != 'O' {
+		return
+	}
+	r, _, _ := e.reader.ReadRune()
+	if r >= '0' && r <= '9' {
+		code := string(r)
+		for {
+			if r, _, _ = e.reader.ReadRune(); r == '~' || len(code) > 3 {
+				break
+			}
+			code += string(r)
+		}
+		switch code {
+		case "1", "7":
+			r = 'H'
+		case "4", "8":
+			r = 'F'
+		case "3":
+			e.delete()
+			return
+		}
+	}
+	switch r {
+	case 'A':
+		e.recall(e.history.previous)
+	case 'B':
+		e.recall(e.history.next)
+	case 'C':
+		e.pos = min(e.pos+1, len(e.buf))
+	case 'D':
+		e.pos = max(e.pos-1, 0)
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.buf)
+	}
+}
+
+// delete removes the character under the cursor.
+func (e *editor) delete() {
+	if e.pos < len(e.buf) {
+		e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+	}
+}
+func (e *editor) recall(move func(current string) (string, bool)) {
+	if line, ok := move(string(e.buf)); ok {
+		e.buf = []rune(line)
+		e.pos = len(e.buf)
+	}
+}
+
+// completeWord completes the word before the cursor as far as all of the
+// candidates agree, and lists them when that adds nothing.
+func (e *editor) completeWord() {
+	start, candidates := e.complete(e.buf, e.pos)
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		common := 0
+		for common < len(prefix) && common < len([]rune(candidate)) && prefix[common] == []rune(candidate)[common] {
+			common++
+		}
+		prefix = prefix[:common]
+	}
+	if len(prefix) > e.pos-start {
+		rest := append(prefix, e.buf[e.pos:]...)
+		e.buf = append(e.buf[:start:start], rest...)
+		e.pos = start + len(prefix)
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+func (e *editor) Close() error {
+	return e.history.close()
+}
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lox_history")
+}
+
+// history holds the lines entered at the prompt, oldest first, and appends
+// new ones to the history file as they are entered. Failing to read or
+// write the file only means that history is not kept.
+type history struct {
+	lines []string
+	// index is the line being recalled, len(lines) while editing a new one.
+	index int
+	// pending is the new line being edited while older ones are recalled.
+	pending string
+	file    *os.File
+}
+
+func loadHistory(path string) *history {
+	h := &history{}
+	if path == "" {
+		return h
+	}
+	if bs, err := os.ReadFile(path); err == nil {
+		h.lines = strings.FieldsFunc(string(bs), func(r rune) bool { return r == '\n' })
+	}
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(h.lines, "\n")+"\n"), 0o600)
+	}
+	h.file, _ = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	h.rewind()
+	return h
+}
+func (h *history) rewind() {
+	h.index = len(h.lines)
+	h.pending = ""
+}
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.file != nil {
+		fmt.Fprintln(h.file, line)
+	}
+}
+func (h *history) previous(current string) (string, bool) {
+	if h.index == 0 {
+		return "", false
+	}
+	if h.index == len(h.lines) {
+		h.pending = current
+	}
+	h.index--
+	return h.lines[h.index], true
+}
+func (h *history) next(string) (string, bool) {
+	if h.index == len(h.lines) {
+		return "", false
+	}
+	h.index++
+	if h.index == len(h.lines) {
+		return h.pending, true
+	}
+	return h.lines[h.index], true
+}
+func (h *history) close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
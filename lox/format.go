@@ -0,0 +1,127 @@
+package lox
+
+import (
+	"fmt"
+	"lox/cst"
+	"lox/scanner"
+	"strings"
+)
+
+// formatSource returns source in the standard layout. It fails with a
+// *CompileError when source does not compile.
+func formatSource(source string) (string, error) {
+	if _, err := compile(source); err != nil {
+		return "", err
+	}
+	root, err := cst.Parse(scanner.NewSacnner(source).KeepComments().ScanTokens())
+	if err != nil {
+		return "", err
+	}
+	return cst.Format(root), nil
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// unifiedDiff returns the changes from a to b in unified format, or "" when
+// they are equal.
+func unifiedDiff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	before := strings.SplitAfter(a, "\n")
+	after := strings.SplitAfter(b, "\n")
+	ops := diffLines(before, after)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s (formatted)\n", name, name)
+	for start := 0; start < len(ops); {
+		// Find the next change and the hunk of changes close to it.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for next := start; next < len(ops); next++ {
+			if ops[next].kind != ' ' {
+				end = next + 1
+			} else if next-end >= 2*diffContext {
+				break
+			}
+		}
+		from, to := max(start-diffContext, 0), min(end+diffContext, len(ops))
+		oldLine, newLine, oldCount, newCount := 1, 1, 0, 0
+		for _, op := range ops[:from] {
+			oldLine += oneIf(op.kind != '+')
+			newLine += oneIf(op.kind != '-')
+		}
+		for _, op := range ops[from:to] {
+			oldCount += oneIf(op.kind != '+')
+			newCount += oneIf(op.kind != '-')
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, op := range ops[from:to] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return sb.String()
+}
+func oneIf(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns the edit script from a to b, based on their longest
+// common subsequence.
+func diffLines(a, b []string) []diffOp {
+	if a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	if b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
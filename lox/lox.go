@@ -1,86 +1,155 @@
 package lox
 
 import (
-	"bufio"
-	"errors"
-	"fmt"
 	"io"
+	"lox/ast"
+	"lox/coverage"
 	er "lox/errors"
 	"lox/interpreter"
 	"lox/parser"
+	"lox/profiler"
+	"lox/resolver"
 	"lox/scanner"
 	"os"
+	"strings"
 )
 
 type Lox struct {
 	script   string
 	executor *interpreter.Interpreter
+	caps     interpreter.Capability
+	args     []string
+	// builtins are the globals the prompt started with, left out by :env.
+	builtins map[string]any
+	// cover records the coverage of RunFile when set.
+	cover *coverage.Profile
+	// profile measures the time RunFile spends when set.
+	profile *profiler.Profile
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
 }
 
+// NewLox returns a Lox that runs script, or reads it from standard input
+// when script is "-".
 func NewLox(script string) *Lox {
 	return &Lox{
 		script:   script,
 		executor: interpreter.NewInterpreter(interpreter.NewEnvironment(nil)),
+		stdin:    os.Stdin,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
 	}
 }
+
+// SetStdio replaces the standard streams used by the script and prompt.
+func (l *Lox) SetStdio(stdin io.Reader, stdout, stderr io.Writer) {
+	l.stdin = stdin
+	l.stdout = stdout
+	l.stderr = stderr
+	l.executor.SetOutput(stdout)
+}
+
+// Grant enables natives that access the filesystem or the OS.
+func (l *Lox) Grant(caps interpreter.Capability) {
+	l.caps |= caps
+	l.executor.Grant(caps)
+}
+
+// SetArgs sets the script arguments returned by the args native.
+func (l *Lox) SetArgs(args []string) {
+	l.args = args
+	l.executor.SetArgs(args)
+}
+
+// Cover makes RunFile record which lines and branches run in profile.
+func (l *Lox) Cover(profile *coverage.Profile) {
+	l.cover = profile
+}
+
+// Profile makes RunFile measure the time and calls of each function and
+// line in profile.
+func (l *Lox) Profile(profile *profiler.Profile) {
+	l.profile = profile
+}
+
+// RunFile runs the script. Compile errors are returned as a
+// *CompileError and runtime errors as an *errors.RuntimeError.
 func (l *Lox) RunFile() error {
-	bs, err := os.ReadFile(l.script)
+	source, stmts, err := l.compileScript()
 	if err != nil {
 		return err
 	}
-	if err = l.run(string(bs)); err != nil {
-		return err
-	}
-	return nil
-}
-func (l *Lox) RunPrompt() error {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("> ")
-		bs, _, err := reader.ReadLine()
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
+	var observers interpreter.Observers
+	if l.cover != nil {
+		if err := l.cover.Add(l.executor.File(), source); err != nil {
 			return err
 		}
-		line := string(bs)
-		l.run(line)
+		observers = append(observers, l.cover.Observer(l.executor))
 	}
-}
-func (l *Lox) Run() {
-	if len(l.script) == 0 {
-		if err := l.RunPrompt(); err != nil {
-			fmt.Fprint(os.Stderr, err)
-		}
-	} else {
-		if err := l.RunFile(); err != nil {
-			fmt.Fprint(os.Stderr, err)
-		}
+	if l.profile != nil {
+		observers = append(observers, l.profile.Observer(l.executor))
+		defer l.profile.Stop()
 	}
+	switch len(observers) {
+	case 0:
+	case 1:
+		l.executor.SetObserver(observers[0])
+	default:
+		l.executor.SetObserver(observers)
+	}
+	_, err = l.executor.Run(stmts)
+	return err
 }
 
-func (l *Lox) run(source string) error {
-	scanner := scanner.NewSacnner(source)
-	tokens := scanner.ScanTokens()
-	// for i, tok := range tokens {
-	// 	fmt.Printf("%d : %+v\n", i, tok)
-	// }
-	par := parser.NewParser(tokens)
-	stmts := par.Parse()
-	ret, err := l.executor.Run(stmts)
+// compileScript reads and compiles the script and returns its source.
+func (l *Lox) compileScript() (string, []ast.Stmt, error) {
+	source, err := readSource(l.script, l.stdin)
 	if err != nil {
-		fmt.Printf("%s\n", err)
+		return "", nil, err
 	}
-	if ret != nil {
-		fmt.Printf("%#v\n", ret)
+	if l.script != "-" {
+		l.executor.SetFile(l.script)
 	}
-	if len(er.Errors) != 0 {
-		for _, err := range er.Errors {
-			fmt.Printf("%+v\n", err)
-		}
-		er.Errors = er.Errors[:0]
-		return fmt.Errorf("scan or parse error")
+	stmts, err := compile(source)
+	return source, stmts, err
+}
+
+// CompileError holds the scan, parse and resolve errors of a program.
+type CompileError struct {
+	Errors []error
+}
+
+func (e *CompileError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// compile scans, parses and resolves source. The errors reported on the
+// way are returned as a *CompileError.
+func compile(source string) ([]ast.Stmt, error) {
+	er.Errors = er.Errors[:0]
+	stmts := parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse()
+	resolver.NewResolver().Resolve(stmts)
+	if len(er.Errors) == 0 {
+		return stmts, nil
+	}
+	err := &CompileError{Errors: append([]error(nil), er.Errors...)}
+	er.Errors = er.Errors[:0]
+	return nil, err
+}
+
+// readSource reads the file at path, or stdin when path is "-".
+func readSource(path string, stdin io.Reader) (string, error) {
+	var bs []byte
+	var err error
+	if path == "-" {
+		bs, err = io.ReadAll(stdin)
+	} else {
+		bs, err = os.ReadFile(path)
 	}
-	return nil
+	return string(bs), err
 }
@@ -0,0 +1,279 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"lox/ast"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/scanner"
+	"lox/token"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+const replHelp = `Enter statements to run them. The value of an expression is printed, and
+its final ';' may be left out. Input with open brackets or strings goes on
+over several lines; Ctrl-C discards it and Ctrl-D quits.
+
+Commands:
+  :help           show this help
+  :env [all]      list the bindings defined in this session, or all globals
+  :ast <code>     print the syntax tree of code
+  :tokens <code>  print the tokens of code
+  :load <file>    run a script in this session
+  :reset          forget every binding
+  :time <code>    run code and print how long it took
+`
+
+// RunPrompt reads and runs statements until the end of input. Input that
+// is still open, such as a block missing its '}', is continued on the
+// next line, and the value of an expression statement is echoed. Errors
+// are reported and leave the state defined so far intact.
+func (l *Lox) RunPrompt() error {
+	reader := newLineReader(l.stdin, l.stdout, l.complete)
+	defer reader.Close()
+	l.builtins = l.bindings()
+	var source strings.Builder
+	for {
+		p := prompt
+		if source.Len() > 0 {
+			p = continuationPrompt
+		}
+		line, err := reader.ReadLine(p)
+		if errors.Is(err, errInterrupt) {
+			source.Reset()
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(l.stdout)
+			return l.runLine(source.String())
+		}
+		if err != nil {
+			return err
+		}
+		if source.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if err := l.command(strings.TrimSpace(line)); err != nil {
+				return err
+			}
+			continue
+		}
+		source.WriteString(line)
+		source.WriteString("\n")
+		if incomplete(source.String()) {
+			continue
+		}
+		if err := l.runLine(source.String()); err != nil {
+			return err
+		}
+		source.Reset()
+	}
+}
+
+// runLine runs one complete input of the prompt. Only an *interpreter.Exit
+// is returned; other errors are reported.
+func (l *Lox) runLine(source string) error {
+	if strings.TrimSpace(source) == "" {
+		return nil
+	}
+	stmts, err := compileInput(source)
+	if err != nil {
+		fmt.Fprintln(l.stderr, err)
+		return nil
+	}
+	ret, err := l.executor.Run(stmts)
+	var exit *interpreter.Exit
+	if errors.As(err, &exit) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(l.stderr, err)
+		return nil
+	}
+	// A line with only comments has no statements.
+	if len(stmts) == 0 {
+		return nil
+	}
+	if _, ok := stmts[len(stmts)-1].(*ast.ExpressionStmt); ok {
+		fmt.Fprintln(l.stdout, interpreter.Repr(ret))
+	}
+	return nil
+}
+
+// compileInput compiles a line typed at the prompt, where the final
+// semicolon of an expression may be left out.
+func compileInput(source string) ([]ast.Stmt, error) {
+	stmts, err := compile(source)
+	if err != nil {
+		if withSemicolon, retry := compile(source + ";"); retry == nil {
+			return withSemicolon, nil
+		}
+	}
+	return stmts, err
+}
+
+// incomplete reports whether source ends inside a string, a block comment
+// or unclosed brackets, so that more lines are needed.
+func incomplete(source string) bool {
+	tokens := scanner.NewSacnner(source).ScanTokens()
+	defer func() { er.Errors = er.Errors[:0] }()
+	for _, err := range er.Errors {
+		msg := err.Error()
+		if strings.HasSuffix(msg, "Unterminated string.") || strings.HasSuffix(msg, "Unterminated block comment.") {
+			return true
+		}
+	}
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.Typ {
+		case token.LEFT_PAREN, token.LEFT_BRACE, token.LEFT_BRACKET:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACE, token.RIGHT_BRACKET:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+var commands = []string{":ast", ":env", ":help", ":load", ":reset", ":time", ":tokens"}
+
+// command runs a meta-command such as ":env". Only an *interpreter.Exit is
+// returned; other errors are reported.
+func (l *Lox) command(line string) error {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":help":
+		fmt.Fprint(l.stdout, replHelp)
+	case ":env":
+		builtins := l.builtins
+		if arg == "all" {
+			builtins = nil
+		}
+		globals := l.executor.Globals()
+		for _, name := range globals.Names() {
+			value, _ := globals.Lookup(name)
+			if builtin, ok := builtins[name]; !ok || builtin != value {
+				fmt.Fprintf(l.stdout, "%s = %s\n", name, interpreter.Repr(value))
+			}
+		}
+	case ":ast":
+		stmts, err := compileInput(arg)
+		if err != nil {
+			fmt.Fprintln(l.stderr, err)
+			return nil
+		}
+		for _, stmt := range stmts {
+			if expr, ok := stmt.(*ast.ExpressionStmt); ok && len(stmts) == 1 {
+				fmt.Fprintln(l.stdout, ast.AstPrinter(expr.Expression))
+			} else {
+				fmt.Fprintln(l.stdout, ast.StmtPrinter(stmt))
+			}
+		}
+	case ":tokens":
+		writeTokens(l.stdout, scanner.NewSacnner(arg).ScanTokens())
+		for _, err := range er.Errors {
+			fmt.Fprintln(l.stderr, err)
+		}
+		er.Errors = er.Errors[:0]
+	case ":load":
+		return l.load(arg)
+	case ":reset":
+		l.reset()
+	case ":time":
+		start := time.Now()
+		if err := l.runLine(arg); err != nil {
+			return err
+		}
+		fmt.Fprintf(l.stdout, "took %s\n", time.Since(start))
+	default:
+		fmt.Fprintf(l.stderr, "Unknown command '%s'. Type :help for a list.\n", name)
+	}
+	return nil
+}
+
+// load runs the script at path in the session, with its imports resolved
+// relative to it.
+func (l *Lox) load(path string) error {
+	source, err := readSource(path, l.stdin)
+	if err != nil {
+		fmt.Fprintln(l.stderr, err)
+		return nil
+	}
+	stmts, err := compile(source)
+	if err != nil {
+		fmt.Fprintln(l.stderr, err)
+		return nil
+	}
+	file := l.executor.File()
+	l.executor.SetFile(path)
+	defer l.executor.SetFile(file)
+	_, err = l.executor.Run(stmts)
+	var exit *interpreter.Exit
+	if errors.As(err, &exit) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(l.stderr, err)
+	}
+	return nil
+}
+
+// reset replaces the interpreter with a fresh one with the same
+// capabilities and arguments.
+func (l *Lox) reset() {
+	l.executor = interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	l.executor.SetOutput(l.stdout)
+	l.executor.Grant(l.caps)
+	l.executor.SetArgs(l.args)
+	l.builtins = l.bindings()
+}
+
+// bindings returns the current global bindings.
+func (l *Lox) bindings() map[string]any {
+	globals := l.executor.Globals()
+	bindings := make(map[string]any)
+	for _, name := range globals.Names() {
+		bindings[name], _ = globals.Lookup(name)
+	}
+	return bindings
+}
+
+// complete offers keywords and globals for the identifier before pos, and
+// the meta-commands at the start of the line.
+func (l *Lox) complete(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && (unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1]) || line[start-1] == '_') {
+		start--
+	}
+	if start == 1 && line[0] == ':' {
+		return 0, completions(commands, string(line[:pos]))
+	}
+	prefix := string(line[start:pos])
+	if prefix == "" {
+		return start, nil
+	}
+	names := l.executor.Globals().Names()
+	for keyword := range token.KeyWords {
+		names = append(names, keyword)
+	}
+	return start, completions(names, prefix)
+}
+func completions(names []string, prefix string) []string {
+	matches := make([]string, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches)
+}
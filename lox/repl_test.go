@@ -0,0 +1,185 @@
+package lox
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPrompt(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{
+			input:  "1 + 2;\n\"a\" + \"b\"\n[1, \"x\", nil];\nprint \"p\";\n",
+			stdout: "> 3\n> \"ab\"\n> [1, \"x\", nil]\n> p\n> \n",
+		},
+		{
+			input:  "fun f(x) {\n  if (x) {\n    return [\n      1];\n  }\n}\nf(true)\n",
+			stdout: "> ... ... ... ... ... > [1]\n> \n",
+		},
+		{
+			input:  "var s = \"two\nlines\";\n/* a\ncomment */ s.len()\n",
+			stdout: "> ... > ... 9\n> \n",
+		},
+		{
+			input:  "var a = 1;\n-\"x\";\nvar b = a +;\na = 2\n{ var a = 3; nope; }\na\n",
+			stdout: "> > > > 2\n> > 2\n> \n",
+			stderr: "Operand must be a number.\n[line 1]\n[line 1] Error at ';': Expect expression.\n",
+		},
+		{
+			input:  "// note\nprint 1;\n/* block */\n",
+			stdout: "> > 1\n> > \n",
+		},
+		{
+			input:  "{ var x = 1; nope; }\nx\n",
+			stdout: "> > > \n",
+			stderr: "Undefined variable 'x'.",
+		},
+		{
+			input:  "const c = 1;\nc = 2;\nc\n",
+			stdout: "> > > 1\n> \n",
+			stderr: "Cannot assign to constant 'c'.",
+		},
+		{
+			input:  "print 1;",
+			stdout: "> 1\n> \n",
+		},
+		{
+			input:  "{\n",
+			stdout: "> ... \n",
+			stderr: "Expect '}' after block.",
+		},
+		{
+			input:  ":help\n",
+			stdout: "> " + replHelp + "> \n",
+		},
+		{
+			input:  "var a = 1;\nfun f() {}\n:env\n:reset\n:env\na\n",
+			stdout: "> > > a = 1\nf = <fn f>\n> > > > \n",
+			stderr: "Undefined variable 'a'.",
+		},
+		{
+			input:  ":env all\n",
+			stdout: "\nclock = <native fn clock>\n",
+		},
+		{
+			input:  ":ast 1 + 2 * 3\n:ast var a = -1;\n:tokens a\n",
+			stdout: "> (+ 1 (* 2 3))\n> (var a (- 1))\n> 1 IDENTIFIER a\n1 EOF \n> \n",
+		},
+		{
+			input:  ":time 1 + 1\n",
+			stdout: "> 2\ntook ",
+		},
+		{
+			input:  ":nope\n",
+			stdout: "> > \n",
+			stderr: "Unknown command ':nope'.",
+		},
+		{
+			input:  ":load ../tests/modules/money.lox\nformat(12)\n:load missing.lox\n",
+			stdout: "> > \"12 EUR\"\n> > \n",
+			stderr: "missing.lox",
+		},
+	}
+	for _, test := range tests {
+		var stdout, stderr strings.Builder
+		lox := NewLox("")
+		lox.SetStdio(strings.NewReader(test.input), &stdout, &stderr)
+		if err := lox.RunPrompt(); err != nil {
+			t.Fatal(err)
+		}
+		// Commands print more than is worth spelling out, so only part of
+		// their output is checked.
+		if !strings.Contains(stdout.String(), test.stdout) || (!strings.HasPrefix(test.input, ":") && stdout.String() != test.stdout) {
+			t.Errorf("%q: expected output %q, got %q", test.input, test.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("%q: expected errors to contain %q, got %q", test.input, test.stderr, stderr.String())
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line       string
+		start      int
+		candidates []string
+	}{
+		{line: "pr", start: 0, candidates: []string{"print"}},
+		{line: "var x = ma", start: 8, candidates: []string{"math"}},
+		{line: "re", start: 0, candidates: []string{"re", "readFile", "readLines", "return"}},
+		{line: "f(", start: 2},
+		{line: ":t", start: 0, candidates: []string{":time", ":tokens"}},
+		{line: "myVar + my", start: 8, candidates: []string{"myVar"}},
+	}
+	for _, test := range tests {
+		lox := NewLox("")
+		lox.runLine("var myVar = 1;")
+		line := []rune(test.line)
+		start, candidates := lox.complete(line, len(line))
+		if start != test.start || strings.Join(candidates, " ") != strings.Join(test.candidates, " ") {
+			t.Errorf("%q: expected %d %v, got %d %v", test.line, test.start, test.candidates, start, candidates)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "print 1;\r", want: "print 1;"},
+		{keys: "print 1\x1b[D2\r", want: "print 21"},
+		{keys: "ab\x01c\x05d\r", want: "cabd"},
+		{keys: "abc\x7f\x7fx\r", want: "ax"},
+		{keys: "abc\x1b[H\x1b[3~\r", want: "bc"},
+		{keys: "abc def\x17\r", want: "abc "},
+		{keys: "abc\x1b[D\x0b\r", want: "ab"},
+		{keys: "\x1b[A\r", want: "second"},
+		{keys: "\x1b[A\x1b[A\r", want: "first"},
+		{keys: "new\x1b[A\x1b[B\r", want: "new"},
+		{keys: "clo\t\r", want: "clock"},
+		{keys: "cl\t\r", want: "cl"},
+		{keys: "x = ma\t.sqrt(4)\r", want: "x = math.sqrt(4)"},
+	}
+	lox := NewLox("")
+	for _, test := range tests {
+		e := &editor{
+			raw:      func() (func(), error) { return func() {}, nil },
+			reader:   bufio.NewReader(strings.NewReader(test.keys)),
+			out:      io.Discard,
+			history:  &history{lines: []string{"first", "second"}},
+			complete: lox.complete,
+		}
+		got, err := e.ReadLine(prompt)
+		if err != nil || got != test.want {
+			t.Errorf("%q: expected %q, got %q (%v)", test.keys, test.want, got, err)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := loadHistory(path)
+	h.add("var a = 1;")
+	h.add("var a = 1;")
+	h.add(" ")
+	h.add("print a;")
+	h.close()
+	h = loadHistory(path)
+	defer h.close()
+	if strings.Join(h.lines, "|") != "var a = 1;|print a;" {
+		t.Errorf("unexpected history %q", h.lines)
+	}
+	if line, _ := h.previous("draft"); line != "print a;" {
+		t.Errorf("expected the last line, got %q", line)
+	}
+	if line, _ := h.next(""); line != "draft" {
+		t.Errorf("expected the draft back, got %q", line)
+	}
+}
@@ -0,0 +1,37 @@
+package lox
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw switches the terminal on fd to raw mode, so that keys arrive as
+// they are pressed and are not echoed, and returns a function restoring
+// the previous mode. It fails when fd is not a terminal.
+func makeRaw(fd uintptr) (func(), error) {
+	var old syscall.Termios
+	if err := termios(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { termios(fd, syscall.TCSETS, &old) }, nil
+}
+func isTerminal(fd uintptr) bool {
+	var t syscall.Termios
+	return termios(fd, syscall.TCGETS, &t) == nil
+}
+func termios(fd uintptr, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package lox
+
+import "errors"
+
+// Line editing needs raw terminal mode, which is only implemented for
+// Linux. Elsewhere the prompt reads plain lines.
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
+func isTerminal(fd uintptr) bool {
+	return false
+}
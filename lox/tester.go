@@ -0,0 +1,291 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"lox/ast"
+	"lox/coverage"
+	er "lox/errors"
+	"lox/interpreter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The expectation comments understood by the test runner. They follow the
+// test suite of Crafting Interpreters, where annotations for the "c"
+// implementation are skipped and those for "java" apply, since this is
+// also a tree-walking interpreter. A script can also name the
+// capabilities it needs, as in "// allow: fs os", which the runner grants
+// it on top of those granted to every script.
+var (
+	expectOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectError        = regexp.MustCompile(`// (Error.*)`)
+	expectErrorLine    = regexp.MustCompile(`// \[((java|c) )?line (\d+)\] (Error.*)`)
+	expectRuntimeError = regexp.MustCompile(`// expect runtime error: (.+)`)
+	allowCapabilities  = regexp.MustCompile(`// allow: (.+)`)
+)
+
+// capabilities maps the names used in allow comments, those of the
+// -allow-fs and -allow-os flags, to what they grant.
+var capabilities = map[string]interpreter.Capability{
+	"fs": interpreter.CapFS,
+	"os": interpreter.CapOS,
+}
+
+// expectations are the annotations collected from one test script.
+type expectations struct {
+	output       []expectedOutput
+	errors       []string
+	runtimeError string
+	runtimeLine  int
+	// caps are the capabilities the script asks for and unknown the
+	// names in its allow comments that are not capabilities.
+	caps    interpreter.Capability
+	unknown []string
+}
+type expectedOutput struct {
+	line int
+	text string
+}
+
+// TestResult is the outcome of running one test script. The script passed
+// when Failures is empty. Tests counts the test blocks that were run and
+// TestsFailed those among them that failed.
+type TestResult struct {
+	Path        string
+	Failures    []string
+	Tests       int
+	TestsFailed int
+}
+
+// RunTests runs every .lox file below the given paths, checks it against
+// its expectation comments and writes a report to w. It returns the number
+// of scripts that passed and failed. The scripts are granted caps, and
+// coverage is recorded in cover unless it is nil.
+func RunTests(paths []string, w io.Writer, caps interpreter.Capability, cover *coverage.Profile) (passed, failed int, err error) {
+	files, err := testFiles(paths)
+	if err != nil {
+		return 0, 0, err
+	}
+	tests, testsFailed := 0, 0
+	for _, file := range files {
+		result, err := RunTestFile(file, caps, cover)
+		if err != nil {
+			return passed, failed, err
+		}
+		tests += result.Tests
+		testsFailed += result.TestsFailed
+		if len(result.Failures) == 0 {
+			passed++
+			fmt.Fprintf(w, "PASS %s\n", result.Path)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL %s\n", result.Path)
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "    %s\n", failure)
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed.\n", passed, failed)
+	if tests > 0 {
+		fmt.Fprintf(w, "%d tests passed, %d failed.\n", tests-testsFailed, testsFailed)
+	}
+	return passed, failed, nil
+}
+func testFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, 16)
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".lox" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// RunTestFile runs one script in a fresh interpreter and compares what it
+// printed and reported with its expectation comments. Each test block in
+// the script then runs in an interpreter of its own. The script is granted
+// caps and those it allows itself, and coverage is recorded in cover
+// unless it is nil.
+func RunTestFile(path string, caps interpreter.Capability, cover *coverage.Profile) (*TestResult, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	source := string(bs)
+	expect := parseExpectations(source)
+	result := &TestResult{Path: path}
+	for _, name := range expect.unknown {
+		result.Failures = append(result.Failures, fmt.Sprintf("unknown capability '%s'", name))
+	}
+	caps |= expect.caps
+
+	var out bytes.Buffer
+	executor := newTestInterpreter(path, &out, caps, cover)
+
+	stmts, err := compile(source)
+	compileErrors := make([]string, 0)
+	if compileErr, ok := err.(*CompileError); ok {
+		for _, err := range compileErr.Errors {
+			compileErrors = append(compileErrors, err.Error())
+		}
+	}
+
+	var runErr error
+	if len(compileErrors) == 0 {
+		if cover != nil {
+			if err := cover.Add(executor.File(), source); err != nil {
+				return nil, err
+			}
+		}
+		_, runErr = executor.Run(stmts)
+	}
+	result.Failures = append(result.Failures, compareOutput(expect.output, out.String())...)
+	result.Failures = append(result.Failures, compareErrors(expect.errors, compileErrors)...)
+	result.Failures = append(result.Failures, compareRuntimeError(expect, runErr)...)
+	if len(compileErrors) == 0 {
+		runTestBlocks(result, stmts, caps, cover)
+	}
+	return result, nil
+}
+func newTestInterpreter(path string, out io.Writer, caps interpreter.Capability, cover *coverage.Profile) *interpreter.Interpreter {
+	executor := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	executor.Grant(caps)
+	executor.SetFile(path)
+	executor.SetOutput(out)
+	if cover != nil {
+		executor.SetObserver(cover.Observer(executor))
+	}
+	return executor
+}
+
+// runTestBlocks runs the test blocks of a script one by one. So that tests
+// cannot affect each other, each one gets a fresh interpreter that first
+// runs the rest of the script.
+func runTestBlocks(result *TestResult, stmts []ast.Stmt, caps interpreter.Capability, cover *coverage.Profile) {
+	for _, stmt := range stmts {
+		test, ok := stmt.(*ast.TestStmt)
+		if !ok {
+			continue
+		}
+		result.Tests++
+		executor := newTestInterpreter(result.Path, io.Discard, caps, cover)
+		_, err := executor.Run(stmts)
+		if err != nil {
+			err = fmt.Errorf("script failed before the test: %w", err)
+		} else {
+			err = executor.RunTest(test)
+		}
+		if err != nil {
+			result.TestsFailed++
+			result.Failures = append(result.Failures, fmt.Sprintf("test %s failed: %s", test.Name.Lexeme, strings.ReplaceAll(err.Error(), "\n", " ")))
+		}
+	}
+}
+func parseExpectations(source string) *expectations {
+	expect := &expectations{}
+	for idx, text := range strings.Split(source, "\n") {
+		line := idx + 1
+		if m := expectOutput.FindStringSubmatch(text); m != nil {
+			expect.output = append(expect.output, expectedOutput{line: line, text: m[1]})
+		} else if m := expectRuntimeError.FindStringSubmatch(text); m != nil {
+			expect.runtimeError = m[1]
+			expect.runtimeLine = line
+		} else if m := allowCapabilities.FindStringSubmatch(text); m != nil {
+			for _, name := range strings.Fields(m[1]) {
+				if c, ok := capabilities[name]; ok {
+					expect.caps |= c
+				} else {
+					expect.unknown = append(expect.unknown, name)
+				}
+			}
+		} else if m := expectErrorLine.FindStringSubmatch(text); m != nil {
+			if m[2] != "c" {
+				expect.errors = append(expect.errors, fmt.Sprintf("[line %s] %s", m[3], m[4]))
+			}
+		} else if m := expectError.FindStringSubmatch(text); m != nil {
+			expect.errors = append(expect.errors, fmt.Sprintf("[line %d] %s", line, m[1]))
+		}
+	}
+	return expect
+}
+
+// compareOutput reports printed lines that differ from the expected ones
+// as a diff, with "-" for expected and "+" for actual output.
+func compareOutput(expected []expectedOutput, output string) []string {
+	actual := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if output == "" {
+		actual = nil
+	}
+	diff := make([]string, 0)
+	for idx := 0; idx < max(len(expected), len(actual)); idx++ {
+		if idx < len(expected) && idx < len(actual) && expected[idx].text == actual[idx] {
+			continue
+		}
+		if idx < len(expected) {
+			diff = append(diff, fmt.Sprintf("- %s (line %d)", expected[idx].text, expected[idx].line))
+		}
+		if idx < len(actual) {
+			diff = append(diff, "+ "+actual[idx])
+		}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	return append([]string{"output differs (- expected, + actual):"}, diff...)
+}
+func compareErrors(expected, actual []string) []string {
+	failures := make([]string, 0)
+	remaining := make(map[string]int, len(expected))
+	for _, err := range expected {
+		remaining[err]++
+	}
+	for _, err := range actual {
+		if remaining[err] > 0 {
+			remaining[err]--
+			continue
+		}
+		failures = append(failures, "unexpected error: "+err)
+	}
+	for _, err := range expected {
+		if remaining[err] > 0 {
+			remaining[err]--
+			failures = append(failures, "missing expected error: "+err)
+		}
+	}
+	return failures
+}
+func compareRuntimeError(expect *expectations, err error) []string {
+	var runtimeErr *er.RuntimeError
+	if err != nil && !errors.As(err, &runtimeErr) {
+		return []string{"unexpected error: " + err.Error()}
+	}
+	if expect.runtimeError == "" {
+		if runtimeErr != nil {
+			return []string{"unexpected runtime error: " + strings.ReplaceAll(runtimeErr.Error(), "\n", " ")}
+		}
+		return nil
+	}
+	want := fmt.Sprintf("%s [line %d]", expect.runtimeError, expect.runtimeLine)
+	if runtimeErr == nil {
+		return []string{"missing expected runtime error: " + want}
+	}
+	got := fmt.Sprintf("%s [line %d]", runtimeErr.Msg, runtimeErr.Token.Line)
+	if got != want {
+		return []string{"wrong runtime error", "- " + want, "+ " + got}
+	}
+	return nil
+}
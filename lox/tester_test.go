@@ -0,0 +1,92 @@
+package lox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestScripts runs the annotated scripts in the tests directory.
+func TestScripts(t *testing.T) {
+	var report strings.Builder
+	_, failed, err := RunTests([]string{"../tests"}, &report, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed > 0 {
+		t.Errorf("%d scripts failed:\n%s", failed, report.String())
+	}
+}
+
+func TestRunTestFile(t *testing.T) {
+	tests := []struct {
+		source   string
+		failures []string
+	}{
+		{
+			source: "print 1; // expect: 1\nprint \"a\" + \"b\"; // expect: ab\n",
+		},
+		{
+			source:   "print 1; // expect: 2\n",
+			failures: []string{"output differs (- expected, + actual):", "- 2 (line 1)", "+ 1"},
+		},
+		{
+			source:   "// expect: missing\n",
+			failures: []string{"output differs (- expected, + actual):", "- missing (line 1)"},
+		},
+		{
+			source: "var a = -\"x\"; // expect runtime error: Operand must be a number.\n",
+		},
+		{
+			source:   "\nvar a = 1; // expect runtime error: Operand must be a number.\n-\"x\";\n",
+			failures: []string{"wrong runtime error", "- Operand must be a number. [line 2]", "+ Operand must be a number. [line 3]"},
+		},
+		{
+			source: "const a; // Error at 'a': Const declaration must be initialized.\n",
+		},
+		{
+			source: "\n// [line 4] Error at 'a': Const declaration must be initialized.\n// [c line 9] Error at 'x': Ignored.\nconst a;",
+		},
+		{
+			source:   "const a;\n",
+			failures: []string{"unexpected error: [line 1] Error at 'a': Const declaration must be initialized."},
+		},
+		{
+			source: "test \"passes\" { assert 1 < 2; }\n",
+		},
+		{
+			source:   "var xs = [1];\ntest \"fails\" {\n  xs.push(2);\n  assert xs.len() == 1 ? true : -xs[0] > 0, \"pushed\";\n}\ntest \"isolated\" { assert xs.len() == 1; }\n",
+			failures: []string{`test "fails" failed: Assertion failed: xs.len() == 1 ? true : -xs[0] > 0: pushed [line 4]`},
+		},
+		{
+			source:   "print 1; // expect: 1\nassert false;\ntest \"skipped\" {}\n",
+			failures: []string{"unexpected runtime error: Assertion failed: false [line 2]", `test "skipped" failed: script failed before the test: Assertion failed: false [line 2]`},
+		},
+		{
+			source: "// allow: os\nprint getenv(\"LOX_MISSING\"); // expect: nil\ntest \"granted\" { assert getenv(\"LOX_MISSING\") == nil; }\n",
+		},
+		{
+			source:   "// allow: fs net\n",
+			failures: []string{"unknown capability 'net'"},
+		},
+	}
+	dir := t.TempDir()
+	for idx, test := range tests {
+		path := filepath.Join(dir, "test.lox")
+		if err := os.WriteFile(path, []byte(test.source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := RunTestFile(path, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(result.Failures, "\n") != strings.Join(test.failures, "\n") {
+			t.Errorf("case %d: expected failures %q, got %q", idx, test.failures, result.Failures)
+		}
+	}
+	if _, _, err := RunTests([]string{filepath.Join(dir, "missing")}, io.Discard, 0, nil); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,218 @@
+package lsp
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"lox/ast"
+	er "lox/errors"
+	"lox/parser"
+	"lox/resolver"
+	"lox/scanner"
+	"lox/token"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is an open file and what was found out about it the last time
+// it changed.
+type document struct {
+	uri         string
+	lines       []string
+	tokens      []*token.Token
+	symbols     []*resolver.Symbol
+	diagnostics []Diagnostic
+}
+
+// analyze scans, parses and resolves text. Statements that fail to parse
+// are left out, so the symbols of the rest are still known.
+func analyze(uri, text string) *document {
+	er.Errors = er.Errors[:0]
+	defer func() { er.Errors = er.Errors[:0] }()
+	doc := &document{uri: uri, lines: strings.Split(text, "\n")}
+	doc.tokens = scanner.NewSacnner(text).ScanTokens()
+	r := resolver.NewResolver()
+	r.Resolve(parser.NewParser(doc.tokens).Parse())
+	doc.symbols = r.Symbols()
+	doc.diagnostics = make([]Diagnostic, 0, len(er.Errors))
+	for _, err := range er.Errors {
+		doc.diagnostics = append(doc.diagnostics, doc.diagnostic(err))
+	}
+	return doc
+}
+func (d *document) diagnostic(err error) Diagnostic {
+	diagnostic := Diagnostic{Severity: severityError, Source: "lox", Message: err.Error()}
+	var syntax *er.SyntaxError
+	if !errors.As(err, &syntax) {
+		return diagnostic
+	}
+	diagnostic.Message = syntax.Msg
+	if syntax.Token != nil {
+		diagnostic.Range = d.tokenRange(syntax.Token)
+		return diagnostic
+	}
+	// The scanner only knows the line, so the whole line is marked.
+	line := syntax.Line - 1
+	diagnostic.Range = Range{Position{line, 0}, Position{line, d.character(line, len(d.line(line)))}}
+	return diagnostic
+}
+
+// tokenRange returns where tok is in the document.
+func (d *document) tokenRange(tok *token.Token) Range {
+	lines := strings.Split(tok.Lexeme, "\n")
+	startLine := tok.Line - len(lines) + 1
+	start := Position{startLine - 1, d.character(startLine-1, tok.Column)}
+	if len(lines) > 1 {
+		end := tok.Line - 1
+		return Range{start, Position{end, d.character(end, len(lines[len(lines)-1]))}}
+	}
+	return Range{start, Position{start.Line, d.character(start.Line, tok.Column+len(tok.Lexeme))}}
+}
+
+// character converts a byte offset in a line to the UTF-16 offset that
+// LSP positions use.
+func (d *document) character(line, offset int) int {
+	text := d.line(line)
+	offset = min(offset, len(text))
+	character := 0
+	for _, r := range text[:offset] {
+		character += utf16Len(r)
+	}
+	return character
+}
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r != utf8.RuneError {
+		return 2
+	}
+	return 1
+}
+func (d *document) line(line int) string {
+	if line < 0 || line >= len(d.lines) {
+		return ""
+	}
+	return d.lines[line]
+}
+func (d *document) location(tok *token.Token) Location {
+	return Location{URI: d.uri, Range: d.tokenRange(tok)}
+}
+
+// symbolAt returns the symbol named at pos, by its declaration or by one
+// of its references, and the token there.
+func (d *document) symbolAt(pos Position) (*resolver.Symbol, *token.Token) {
+	for _, symbol := range d.symbols {
+		if d.tokenRange(&symbol.Name).contains(pos) {
+			return symbol, &symbol.Name
+		}
+		for i := range symbol.Refs {
+			if d.tokenRange(&symbol.Refs[i]).contains(pos) {
+				return symbol, &symbol.Refs[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// renamed returns the text of d with symbol renamed to name where it is
+// declared and used.
+func (d *document) renamed(symbol *resolver.Symbol, name string) string {
+	lines := slices.Clone(d.lines)
+	toks := append([]token.Token{symbol.Name}, symbol.Refs...)
+	// Going backwards keeps the columns of the tokens left to rename.
+	slices.SortFunc(toks, func(a, b token.Token) int {
+		return cmp.Or(cmp.Compare(b.Line, a.Line), cmp.Compare(b.Column, a.Column))
+	})
+	for _, tok := range toks {
+		line := lines[tok.Line-1]
+		lines[tok.Line-1] = line[:tok.Column] + name + line[tok.Column+len(tok.Lexeme):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bindings describes which declaration each name of d refers to by the
+// indexes of their tokens, which a rename keeps.
+func (d *document) bindings() []string {
+	index := make(map[[2]int]int, len(d.tokens))
+	for i, tok := range d.tokens {
+		index[[2]int{tok.Line, tok.Column}] = i
+	}
+	bindings := make([]string, 0, len(d.symbols))
+	for _, symbol := range d.symbols {
+		binding := strconv.Itoa(index[[2]int{symbol.Name.Line, symbol.Name.Column}])
+		for _, ref := range symbol.Refs {
+			binding += "," + strconv.Itoa(index[[2]int{ref.Line, ref.Column}])
+		}
+		bindings = append(bindings, binding)
+	}
+	slices.Sort(bindings)
+	return bindings
+}
+
+// tokenAt returns the token at pos, or nil.
+func (d *document) tokenAt(pos Position) *token.Token {
+	for _, tok := range d.tokens {
+		if tok.Typ != token.EOF && d.tokenRange(tok).contains(pos) {
+			return tok
+		}
+	}
+	return nil
+}
+
+// propertyAt returns the name after a dot at pos, or nil. It names a map
+// key or a module member rather than a declaration.
+func (d *document) propertyAt(pos Position) *token.Token {
+	for i, tok := range d.tokens {
+		if i > 0 && d.tokens[i-1].Typ == token.DOT && tok.Typ == token.IDENTIFIER && d.tokenRange(tok).contains(pos) {
+			return tok
+		}
+	}
+	return nil
+}
+
+// describe returns a Lox snippet for the declaration of symbol, and what
+// kind of value it holds when that is known without running the program.
+func describe(symbol *resolver.Symbol) (string, string) {
+	switch {
+	case symbol.Param:
+		return "(parameter) " + symbol.Name.Lexeme, ""
+	case symbol.Kind == token.FUN:
+		params := make([]string, 0, len(symbol.Params))
+		for _, param := range symbol.Params {
+			params = append(params, param.Lexeme)
+		}
+		return fmt.Sprintf("fun %s(%s)", symbol.Name.Lexeme, strings.Join(params, ", ")), "function"
+	}
+	return strings.ToLower(symbol.Kind.String()) + " " + symbol.Name.Lexeme, valueKind(symbol)
+}
+
+// valueKind names the type of the value a variable starts with, or ""
+// when it depends on running the program.
+func valueKind(symbol *resolver.Symbol) string {
+	switch value := symbol.Value.(type) {
+	case nil:
+		// Constants must be initialized, so one without a value is the
+		// name of an import.
+		if symbol.Kind == token.CONST {
+			return "module"
+		}
+		return "nil"
+	case *ast.LiteralNode:
+		switch value.Value.(type) {
+		case float64:
+			return "number"
+		case string:
+			return "string"
+		case bool:
+			return "boolean"
+		}
+		return "nil"
+	case *ast.LambdaNode:
+		return "function"
+	case *ast.ListNode:
+		return "list"
+	case *ast.MapNode:
+		return "map"
+	}
+	return ""
+}
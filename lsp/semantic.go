@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	"lox/resolver"
+	"lox/token"
+)
+
+// The legend of semantic tokens. A token's type is an index in
+// semanticTypes and its modifiers a bit set of semanticModifiers.
+var (
+	semanticTypes     = []string{"keyword", "variable", "parameter", "function", "string", "number", "property"}
+	semanticModifiers = []string{"declaration", "readonly", "defaultLibrary", "global", "local"}
+)
+
+const (
+	typeKeyword = iota
+	typeVariable
+	typeParameter
+	typeFunction
+	typeString
+	typeNumber
+	typeProperty
+)
+
+const (
+	modDeclaration = 1 << iota
+	modReadonly
+	modDefaultLibrary
+	modGlobal
+	modLocal
+)
+
+// semanticTokens classifies the keywords, names and literals of a
+// document. Names are told apart by the symbols they refer to: globals,
+// locals and parameters, with functions, whether declared with fun or
+// bound to a function literal, as functions. A name after a dot is a
+// property, a map key or a member of a module.
+func (s *Server) semanticTokens(params documentParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	type position struct{ line, column int }
+	type use struct {
+		symbol *resolver.Symbol
+		decl   bool
+	}
+	uses := make(map[position]use)
+	for _, symbol := range doc.symbols {
+		uses[position{symbol.Name.Line, symbol.Name.Column}] = use{symbol, true}
+		for _, ref := range symbol.Refs {
+			uses[position{ref.Line, ref.Column}] = use{symbol, false}
+		}
+	}
+	data := make([]int, 0)
+	var last Position
+	for i, tok := range doc.tokens {
+		keyword, isKeyword := token.KeyWords[tok.Lexeme]
+		typ, mods := -1, 0
+		switch {
+		case isKeyword && keyword == tok.Typ:
+			typ = typeKeyword
+		case tok.Typ == token.IDENTIFIER && tok.Lexeme == "test" && i+1 < len(doc.tokens) && doc.tokens[i+1].Typ == token.STRING:
+			typ = typeKeyword
+		case tok.Typ == token.STRING:
+			typ = typeString
+		case tok.Typ == token.NUMBER:
+			typ = typeNumber
+		case tok.Typ == token.IDENTIFIER && i > 0 && doc.tokens[i-1].Typ == token.DOT:
+			typ = typeProperty
+		case tok.Typ == token.IDENTIFIER:
+			if use, ok := uses[position{tok.Line, tok.Column}]; ok {
+				typ, mods = classify(use.symbol)
+				if use.decl {
+					mods |= modDeclaration
+				}
+			} else if _, ok := s.builtins[tok.Lexeme]; ok {
+				typ, mods = typeFunction, modDefaultLibrary|modGlobal
+			} else {
+				typ = typeVariable
+			}
+		}
+		if typ < 0 {
+			continue
+		}
+		// Tokens may not span lines, so a multi-line string is marked
+		// line by line.
+		r := doc.tokenRange(tok)
+		for line := r.Start.Line; line <= r.End.Line; line++ {
+			start, end := 0, r.End.Character
+			if line == r.Start.Line {
+				start = r.Start.Character
+			}
+			if line < r.End.Line {
+				end = doc.character(line, len(doc.line(line)))
+			}
+			if end <= start {
+				continue
+			}
+			if line != last.Line {
+				last.Character = 0
+			}
+			data = append(data, line-last.Line, start-last.Character, end-start, typ, mods)
+			last = Position{line, start}
+		}
+	}
+	return semanticTokens{Data: data}
+}
+
+// classify returns the semantic token type and modifiers of a name
+// referring to symbol.
+func classify(symbol *resolver.Symbol) (int, int) {
+	mods := modLocal
+	if symbol.Depth == 0 {
+		mods = modGlobal
+	}
+	if symbol.Kind == token.CONST {
+		mods |= modReadonly
+	}
+	switch {
+	case symbol.Param:
+		return typeParameter, mods
+	case symbol.Kind == token.FUN || valueKind(symbol) == "function":
+		return typeFunction, mods
+	}
+	return typeVariable, mods
+}
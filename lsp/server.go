@@ -0,0 +1,384 @@
+// Package lsp implements a Language Server Protocol server for Lox. It
+// speaks JSON-RPC over a pair of streams, normally stdin and stdout, and
+// keeps the open documents in memory with their whole text.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	er "lox/errors"
+	"lox/interpreter"
+	"lox/scanner"
+	"lox/token"
+	"lox/wire"
+	"slices"
+)
+
+// Server answers the requests of one client.
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+	// builtins are the globals every program starts with.
+	builtins map[string]any
+
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	globals := interpreter.NewInterpreter(interpreter.NewEnvironment(nil)).Globals()
+	builtins := make(map[string]any)
+	for _, name := range globals.Names() {
+		builtins[name], _ = globals.Lookup(name)
+	}
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+		builtins:  builtins,
+	}
+}
+
+// errExitWithoutShutdown is returned by Run when the client sends exit
+// without asking for a shutdown first.
+var errExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Run serves requests until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		body, err := wire.ReadMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := &message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			if err := s.fail(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers msg. Only failures to write are returned; the others are
+// sent to the client.
+func (s *Server) handle(msg *message) error {
+	if !s.initialized && msg.Method != "initialize" {
+		if msg.ID == nil {
+			return nil
+		}
+		return s.fail(msg.ID, codeNotInitialized, "server not initialized")
+	}
+	var result any
+	var err error
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		result = s.capabilities()
+	case "initialized":
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			return s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	case "textDocument/hover":
+		var params positionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/definition":
+		var params positionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/references":
+		var params referenceParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	case "textDocument/documentSymbol":
+		var params documentParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.documentSymbols(params)
+		}
+	case "textDocument/completion":
+		var params positionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.completion(params)
+		}
+	case "textDocument/prepareRename":
+		var params positionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.prepareRename(params)
+		}
+	case "textDocument/rename":
+		var params renameParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			var failure error
+			if result, failure = s.rename(params); failure != nil && msg.ID != nil {
+				return s.fail(msg.ID, codeRequestFailed, failure.Error())
+			}
+		}
+	case "textDocument/semanticTokens/full":
+		var params documentParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.semanticTokens(params)
+		}
+	default:
+		if msg.ID == nil {
+			// Notifications the server does not know are ignored.
+			return nil
+		}
+		return s.fail(msg.ID, codeMethodNotFound, fmt.Sprintf("method not found: %s", msg.Method))
+	}
+	if msg.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return s.fail(msg.ID, codeInvalidParams, err.Error())
+	}
+	return wire.WriteMessage(s.out, response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+func (s *Server) capabilities() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			// The client sends the whole text on each change.
+			"textDocumentSync":       1,
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]any{},
+			"renameProvider":         map[string]any{"prepareProvider": true},
+			"semanticTokensProvider": map[string]any{
+				"legend": map[string]any{"tokenTypes": semanticTypes, "tokenModifiers": semanticModifiers},
+				"full":   true,
+			},
+		},
+		"serverInfo": map[string]any{"name": "lox"},
+	}
+}
+func (s *Server) fail(id *json.RawMessage, code int, msg string) error {
+	return wire.WriteMessage(s.out, errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{Code: code, Message: msg}})
+}
+func (s *Server) notify(method string, params any) error {
+	return wire.WriteMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := analyze(uri, text)
+	s.documents[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: doc.diagnostics})
+}
+
+// hover describes the variable or function at the position: its
+// declaration and the kind of value it holds, or the value of a builtin.
+func (s *Server) hover(params positionParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	var text string
+	var at *token.Token
+	if symbol, tok := doc.symbolAt(params.Position); symbol != nil {
+		decl, kind := describe(symbol)
+		text = "```lox\n" + decl + "\n```"
+		if kind != "" {
+			text += "\n\n" + kind
+		}
+		at = tok
+	} else if tok := doc.tokenAt(params.Position); tok != nil && tok.Typ == token.IDENTIFIER {
+		builtin, ok := s.builtins[tok.Lexeme]
+		if !ok {
+			return nil
+		}
+		text = "```lox\n" + interpreter.Repr(builtin) + "\n```\n\nbuiltin"
+		at = tok
+	} else {
+		return nil
+	}
+	return hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: doc.tokenRange(at)}
+}
+func (s *Server) definition(params positionParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, _ := doc.symbolAt(params.Position)
+	if symbol == nil {
+		return nil
+	}
+	return doc.location(&symbol.Name)
+}
+func (s *Server) references(params referenceParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, _ := doc.symbolAt(params.Position)
+	if symbol == nil {
+		return nil
+	}
+	locations := make([]Location, 0, len(symbol.Refs)+1)
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, doc.location(&symbol.Name))
+	}
+	for i := range symbol.Refs {
+		locations = append(locations, doc.location(&symbol.Refs[i]))
+	}
+	return locations
+}
+
+// documentSymbols lists the globals and the functions of a document.
+func (s *Server) documentSymbols(params documentParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbols := make([]symbolInformation, 0)
+	for _, symbol := range doc.symbols {
+		if symbol.Depth > 0 && symbol.Kind != token.FUN {
+			continue
+		}
+		kind := symbolVariable
+		switch symbol.Kind {
+		case token.FUN:
+			kind = symbolFunction
+		case token.CONST:
+			kind = symbolConstant
+		}
+		symbols = append(symbols, symbolInformation{Name: symbol.Name.Lexeme, Kind: kind, Location: doc.location(&symbol.Name)})
+	}
+	return symbols
+}
+
+// completion offers the keywords, the builtins and the names declared in
+// the document. The client narrows them down to the word being typed.
+func (s *Server) completion(params positionParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	items := make([]completionItem, 0)
+	seen := make(map[string]bool)
+	add := func(item completionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+	for _, symbol := range doc.symbols {
+		decl, _ := describe(symbol)
+		kind := completionVariable
+		switch symbol.Kind {
+		case token.FUN:
+			kind = completionFunction
+		case token.CONST:
+			kind = completionConstant
+		}
+		add(completionItem{Label: symbol.Name.Lexeme, Kind: kind, Detail: decl})
+	}
+	for _, name := range sortedKeys(s.builtins) {
+		add(completionItem{Label: name, Kind: completionFunction, Detail: interpreter.Repr(s.builtins[name])})
+	}
+	for _, keyword := range sortedKeys(token.KeyWords) {
+		add(completionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items
+}
+
+// prepareRename returns the name at the position if it can be renamed.
+func (s *Server) prepareRename(params positionParams) any {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, tok := doc.symbolAt(params.Position)
+	if symbol == nil {
+		return nil
+	}
+	return prepareRenameResult{Range: doc.tokenRange(tok), Placeholder: symbol.Name.Lexeme}
+}
+
+// rename renames the variable or function at the position where it is
+// declared and everywhere it is used. Other declarations of the same name,
+// such as one shadowing it in a nested block, are left alone. A rename
+// that would make a name refer to another declaration, or clash with one,
+// is refused. Properties cannot be renamed: Lox has no classes, so a name
+// after a dot is a map key that exists only at run time, or a member of a
+// module declared in another file.
+func (s *Server) rename(params renameParams) (any, error) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", params.TextDocument.URI)
+	}
+	symbol, _ := doc.symbolAt(params.Position)
+	if symbol == nil {
+		if tok := doc.propertyAt(params.Position); tok != nil {
+			return nil, fmt.Errorf("cannot rename property '%s': properties are map keys or module members, not declarations, since Lox has no classes", tok.Lexeme)
+		}
+		return nil, errors.New("no variable or function to rename here")
+	}
+	if !isIdentifier(params.NewName) {
+		return nil, fmt.Errorf("'%s' is not a valid name", params.NewName)
+	}
+	// Resolving the renamed text again finds the names the new one would
+	// capture or be captured by.
+	after := analyze(doc.uri, doc.renamed(symbol, params.NewName))
+	if len(after.diagnostics) > len(doc.diagnostics) || !slices.Equal(doc.bindings(), after.bindings()) {
+		return nil, fmt.Errorf("renaming '%s' to '%s' would change what names refer to", symbol.Name.Lexeme, params.NewName)
+	}
+	edits := []textEdit{{Range: doc.tokenRange(&symbol.Name), NewText: params.NewName}}
+	for i := range symbol.Refs {
+		edits = append(edits, textEdit{Range: doc.tokenRange(&symbol.Refs[i]), NewText: params.NewName})
+	}
+	return workspaceEdit{Changes: map[string][]textEdit{doc.uri: edits}}, nil
+}
+
+// isIdentifier reports whether name scans as a single identifier.
+func isIdentifier(name string) bool {
+	er.Errors = er.Errors[:0]
+	tokens := scanner.NewSacnner(name).ScanTokens()
+	valid := len(er.Errors) == 0 && len(tokens) == 2 && tokens[0].Typ == token.IDENTIFIER && tokens[0].Lexeme == name
+	er.Errors = er.Errors[:0]
+	return valid
+}
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
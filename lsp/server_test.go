@@ -0,0 +1,255 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"lox/wire"
+	"strings"
+	"testing"
+)
+
+const source = `var count = 1;
+fun add(a, b) {
+  var count = a + b;
+  return count;
+}
+print add(count, 2);
+print clock();
+print count;
+print 1 +;`
+
+// session sends requests to a server, numbered from 1 when they are not
+// notifications, and returns every message it wrote keyed by the ID of
+// the request answered, or by the method for notifications.
+func session(t *testing.T, requests ...map[string]any) map[string]json.RawMessage {
+	var in strings.Builder
+	id := 0
+	for _, request := range requests {
+		request["jsonrpc"] = "2.0"
+		if _, notification := request["notification"]; notification {
+			delete(request, "notification")
+		} else {
+			id++
+			request["id"] = id
+		}
+		body, _ := json.Marshal(request)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out strings.Builder
+	if err := NewServer(strings.NewReader(in.String()), &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+	replies := make(map[string]json.RawMessage)
+	r := bufio.NewReader(strings.NewReader(out.String()))
+	for {
+		body, err := wire.ReadMessage(r)
+		if err != nil {
+			break
+		}
+		var reply struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case reply.ID == nil:
+			replies[reply.Method] = reply.Params
+		case reply.Error != nil:
+			replies[fmt.Sprint(*reply.ID)] = reply.Error
+		default:
+			replies[fmt.Sprint(*reply.ID)] = reply.Result
+		}
+	}
+	return replies
+}
+func at(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": "file:///a.lox"},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	replies := session(t,
+		map[string]any{"method": "initialize", "params": map[string]any{}},
+		map[string]any{"method": "initialized", "notification": true, "params": map[string]any{}},
+		map[string]any{"method": "textDocument/didOpen", "notification": true, "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///a.lox", "text": source},
+		}},
+		map[string]any{"method": "textDocument/hover", "params": at(5, 7)},
+		map[string]any{"method": "textDocument/hover", "params": at(5, 12)},
+		map[string]any{"method": "textDocument/definition", "params": at(3, 10)},
+		map[string]any{"method": "textDocument/references", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///a.lox"},
+			"position":     map[string]any{"line": 0, "character": 5},
+			"context":      map[string]any{"includeDeclaration": true},
+		}},
+		map[string]any{"method": "textDocument/documentSymbol", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///a.lox"},
+		}},
+		map[string]any{"method": "textDocument/hover", "params": at(6, 7)},
+		map[string]any{"method": "textDocument/completion", "params": at(6, 0)},
+		map[string]any{"method": "textDocument/rangeFormatting", "params": map[string]any{}},
+		map[string]any{"method": "shutdown"},
+		map[string]any{"method": "exit", "notification": true},
+	)
+	tests := []struct {
+		key      string
+		contains []string
+	}{
+		{"1", []string{`"hoverProvider":true`, `"textDocumentSync":1`}},
+		{"textDocument/publishDiagnostics", []string{`"range":{"start":{"line":8,"character":9},"end":{"line":8,"character":10}}`, `"message":"Expect expression."`}},
+		{"2", []string{"fun add(a, b)", "function"}},
+		{"3", []string{"var count", "number", `"start":{"line":5,"character":10}`}},
+		{"4", []string{`"start":{"line":2,"character":6}`}},
+		{"5", []string{`{"line":0,"character":4}`, `{"line":5,"character":10}`, `{"line":7,"character":6}`}},
+		{"6", []string{`"name":"count","kind":13`, `"name":"add","kind":12`}},
+		{"7", []string{"native fn clock", "builtin"}},
+		{"8", []string{`"label":"count"`, `"label":"print"`, `"label":"clock"`}},
+		{"9", []string{"-32601"}},
+		{"10", []string{"null"}},
+	}
+	for _, test := range tests {
+		reply, ok := replies[test.key]
+		if !ok {
+			t.Errorf("%s: no reply", test.key)
+			continue
+		}
+		for _, want := range test.contains {
+			if !strings.Contains(string(reply), want) {
+				t.Errorf("%s: expected %s to contain %s", test.key, reply, want)
+			}
+		}
+	}
+	if refs := strings.Count(string(replies["5"]), `"uri"`); refs != 3 {
+		t.Errorf("expected 3 references to count, got %d: %s", refs, replies["5"])
+	}
+}
+
+func open(text string) map[string]any {
+	return map[string]any{"method": "textDocument/didOpen", "notification": true, "params": map[string]any{
+		"textDocument": map[string]any{"uri": "file:///a.lox", "text": text},
+	}}
+}
+func rename(line, character int, name string) map[string]any {
+	params := at(line, character)
+	params["newName"] = name
+	return map[string]any{"method": "textDocument/rename", "params": params}
+}
+
+func TestRename(t *testing.T) {
+	replies := session(t,
+		map[string]any{"method": "initialize", "params": map[string]any{}},
+		open("var x = 1;\nfun f(x) {\n  { var x = 2; print x; }\n  return x;\n}\nprint f(x);"),
+		rename(1, 6, "y"),
+		rename(0, 4, "z"),
+		rename(5, 6, "g"),
+		rename(0, 4, "print"),
+		rename(0, 4, "1a"),
+		rename(5, 0, "p"),
+		map[string]any{"method": "textDocument/prepareRename", "params": at(3, 10)},
+		open("var a = 1;\n{ var b = 2; print a + b; }\nfun f() { return clock(); }\nlet c = 3;\nlet d = 4;"),
+		rename(1, 6, "a"),
+		rename(0, 4, "b"),
+		rename(0, 4, "clock"),
+		rename(4, 4, "c"),
+		rename(1, 6, "e"),
+		open("var m = {};\nm.size = 1;\nprint m.size;"),
+		rename(2, 9, "count"),
+	)
+	edits := func(positions ...[2]int) string {
+		parts := make([]string, 0, len(positions))
+		for _, pos := range positions {
+			parts = append(parts, fmt.Sprintf(`"start":{"line":%d,"character":%d}`, pos[0], pos[1]))
+		}
+		return strings.Join(parts, "")
+	}
+	tests := []struct {
+		key   string
+		edits string
+		name  string
+	}{
+		{"2", edits([2]int{1, 6}, [2]int{3, 9}), "y"},
+		{"3", edits([2]int{0, 4}, [2]int{5, 8}), "z"},
+		{"4", edits([2]int{1, 4}, [2]int{5, 6}), "g"},
+		{"13", edits([2]int{1, 6}, [2]int{1, 23}), "e"},
+	}
+	for _, test := range tests {
+		var edit workspaceEdit
+		if err := json.Unmarshal(replies[test.key], &edit); err != nil {
+			t.Fatalf("%s: %v: %s", test.key, err, replies[test.key])
+		}
+		var got strings.Builder
+		for _, change := range edit.Changes["file:///a.lox"] {
+			fmt.Fprintf(&got, `"start":{"line":%d,"character":%d}`, change.Range.Start.Line, change.Range.Start.Character)
+			if change.NewText != test.name {
+				t.Errorf("%s: expected new text %s, got %s", test.key, test.name, change.NewText)
+			}
+		}
+		if got.String() != test.edits {
+			t.Errorf("%s: expected edits %s, got %s", test.key, test.edits, got.String())
+		}
+	}
+	// 9 to 12 would capture a or be captured by it, capture clock, or
+	// clash with another let.
+	for _, key := range []string{"5", "6", "7", "9", "10", "11", "12"} {
+		if !strings.Contains(string(replies[key]), "-32803") {
+			t.Errorf("%s: expected the rename to fail, got %s", key, replies[key])
+		}
+	}
+	if !strings.Contains(string(replies["14"]), "cannot rename property 'size'") {
+		t.Errorf("expected renaming a map key to fail, got %s", replies["14"])
+	}
+	if !strings.Contains(string(replies["8"]), `"placeholder":"x"`) || !strings.Contains(string(replies["8"]), `"start":{"line":3,"character":9}`) {
+		t.Errorf("unexpected prepareRename result %s", replies["8"])
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	replies := session(t,
+		map[string]any{"method": "initialize", "params": map[string]any{}},
+		open("const k = 1;\nfun f(a) { return a + k + clock(); }\nprint k.x;"),
+		map[string]any{"method": "textDocument/semanticTokens/full", "params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///a.lox"},
+		}},
+	)
+	var tokens semanticTokens
+	if err := json.Unmarshal(replies["2"], &tokens); err != nil {
+		t.Fatal(err)
+	}
+	// Each token is its line, start, length, type and modifiers.
+	expected := [][5]int{
+		{0, 0, 5, typeKeyword, 0},
+		{0, 6, 1, typeVariable, modDeclaration | modReadonly | modGlobal},
+		{0, 10, 1, typeNumber, 0},
+		{1, 0, 3, typeKeyword, 0},
+		{1, 4, 1, typeFunction, modDeclaration | modGlobal},
+		{1, 6, 1, typeParameter, modDeclaration | modLocal},
+		{1, 11, 6, typeKeyword, 0},
+		{1, 18, 1, typeParameter, modLocal},
+		{1, 22, 1, typeVariable, modReadonly | modGlobal},
+		{1, 26, 5, typeFunction, modDefaultLibrary | modGlobal},
+		{2, 0, 5, typeKeyword, 0},
+		{2, 6, 1, typeVariable, modReadonly | modGlobal},
+		{2, 8, 1, typeProperty, 0},
+	}
+	var actual [][5]int
+	line, start := 0, 0
+	for i := 0; i+5 <= len(tokens.Data); i += 5 {
+		if tokens.Data[i] > 0 {
+			start = 0
+		}
+		line += tokens.Data[i]
+		start += tokens.Data[i+1]
+		actual = append(actual, [5]int{line, start, tokens.Data[i+2], tokens.Data[i+3], tokens.Data[i+4]})
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected tokens %v, got %v", expected, actual)
+	}
+}
@@ -1,14 +1,10 @@
 package main
 
 import (
-	"flag"
 	"lox/lox"
+	"os"
 )
 
 func main() {
-	var script string
-	flag.StringVar(&script, "script", "", "lox -script [script]")
-	flag.Parse()
-	lox := lox.NewLox(script)
-	lox.Run()
+	os.Exit(lox.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
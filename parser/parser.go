@@ -10,6 +10,9 @@ import (
 type Parser struct {
 	tokens  []*token.Token
 	current int
+	// funDepth counts the function bodies enclosing the current token so
+	// that a stray return at the top level can be reported.
+	funDepth int
 }
 
 func NewParser(tokens []*token.Token) *Parser {
@@ -26,12 +29,132 @@ func (p *Parser) Parse() []ast.Stmt {
 	return stmts
 }
 func (p *Parser) declaration() ast.Stmt {
-	if p.match(token.VAR) {
+	if p.match(token.IMPORT) {
+		return p.importDeclaration()
+	}
+	if p.match(token.EXPORT) {
+		return p.exportDeclaration()
+	}
+	if p.match(token.VAR, token.LET, token.CONST) {
 		return p.varDeclaration()
 	}
+	// 'test' is only special before a name, so it stays usable as an
+	// identifier.
+	if p.check(token.IDENTIFIER) && p.peek().Lexeme == "test" && p.checkNext(token.STRING) {
+		p.advance()
+		return p.testDeclaration()
+	}
+	// "fun (" starts an anonymous function expression, not a declaration.
+	if p.check(token.FUN) && p.checkNext(token.IDENTIFIER) {
+		p.advance()
+		return p.function("function")
+	}
 	return p.statement()
 }
+func (p *Parser) function(kind string) ast.Stmt {
+	name, err := p.consume(token.IDENTIFIER, "Expect "+kind+" name.")
+	if err != nil {
+		return nil
+	}
+	params, body := p.functionBody(kind)
+	return &ast.FunctionStmt{
+		Name:   *name,
+		Params: params,
+		Body:   body,
+	}
+}
+func (p *Parser) functionBody(kind string) ([]token.Token, []ast.Stmt) {
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after "+kind+" name."); err != nil {
+		return nil, nil
+	}
+	params := p.parameters()
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after parameters."); err != nil {
+		return nil, nil
+	}
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' before "+kind+" body."); err != nil {
+		return nil, nil
+	}
+	p.funDepth++
+	defer func() { p.funDepth-- }()
+	return params, p.blockStatement()
+}
+func (p *Parser) parameters() []token.Token {
+	params := make([]token.Token, 0, 4)
+	if p.check(token.RIGHT_PAREN) {
+		return params
+	}
+	for {
+		if len(params) >= 255 {
+			errors.Error(p.peek(), "Can't have more than 255 parameters.")
+		}
+		param, err := p.consume(token.IDENTIFIER, "Expect parameter name.")
+		if err != nil {
+			return params
+		}
+		params = append(params, *param)
+		if !p.match(token.COMMA) {
+			return params
+		}
+	}
+}
+func (p *Parser) importDeclaration() ast.Stmt {
+	keyword := p.previous()
+	path, err := p.consume(token.STRING, "Expect module path after 'import'.")
+	if err != nil {
+		return nil
+	}
+	// 'as' is only special here, so it stays usable as an identifier.
+	if !p.check(token.IDENTIFIER) || p.peek().Lexeme != "as" {
+		errors.Error(p.peek(), "Expect 'as' after module path.")
+		p.sync()
+		return nil
+	}
+	p.advance()
+	name, err := p.consume(token.IDENTIFIER, "Expect module name after 'as'.")
+	if err != nil {
+		return nil
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after import."); err != nil {
+		return nil
+	}
+	return &ast.ImportStmt{
+		Keyword: *keyword,
+		Path:    *path,
+		Name:    *name,
+	}
+}
+func (p *Parser) exportDeclaration() ast.Stmt {
+	keyword := p.previous()
+	var decl ast.Stmt
+	switch {
+	case p.match(token.VAR, token.LET, token.CONST):
+		decl = p.varDeclaration()
+	case p.match(token.FUN):
+		decl = p.function("function")
+	default:
+		errors.Error(p.peek(), "Expect declaration after 'export'.")
+		p.sync()
+		return nil
+	}
+	return &ast.ExportStmt{
+		Keyword: *keyword,
+		Decl:    decl,
+	}
+}
+func (p *Parser) testDeclaration() ast.Stmt {
+	keyword := p.previous()
+	name := p.advance()
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' before test body."); err != nil {
+		return nil
+	}
+	return &ast.TestStmt{
+		Keyword: *keyword,
+		Name:    *name,
+		Body:    p.blockStatement(),
+	}
+}
 func (p *Parser) varDeclaration() ast.Stmt {
+	kind := p.previous().Typ
 	name, err := p.consume(token.IDENTIFIER, "Expect variable name.")
 	if err != nil {
 		return nil
@@ -39,9 +162,12 @@ func (p *Parser) varDeclaration() ast.Stmt {
 	var value ast.Expr
 	if p.match(token.EQUAL) {
 		value = p.comma()
+	} else if kind == token.CONST {
+		errors.Error(name, "Const declaration must be initialized.")
 	}
 	p.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
 	return &ast.VariableStmt{
+		Kind:  kind,
 		Name:  *name,
 		Value: value,
 	}
@@ -53,14 +179,23 @@ func (p *Parser) statement() ast.Stmt {
 	if p.match(token.PRINT) {
 		return p.printStatement()
 	}
+	if p.match(token.RETURN) {
+		return p.returnStatement()
+	}
+	if p.match(token.ASSERT) {
+		return p.assertStatement()
+	}
 	if p.match(token.LEFT_BRACE) {
+		brace := p.previous()
 		return &ast.BlockStmt{
+			Brace: *brace,
 			Stmts: p.blockStatement(),
 		}
 	}
 	return p.exprStatement()
 }
 func (p *Parser) ifStatement() ast.Stmt {
+	keyword := p.previous()
 	p.consume(token.LEFT_PAREN, "Expect '(' after 'if'.")
 	cond := p.comma()
 	p.consume(token.RIGHT_PAREN, "Expect ')' after if condition.")
@@ -70,9 +205,10 @@ func (p *Parser) ifStatement() ast.Stmt {
 		elseBranch = p.statement()
 	}
 	return &ast.IfStmt{
-		Cond: cond,
-		Then: then,
-		Else: elseBranch,
+		Keyword: *keyword,
+		Cond:    cond,
+		Then:    then,
+		Else:    elseBranch,
 	}
 }
 func (p *Parser) blockStatement() []ast.Stmt {
@@ -93,12 +229,47 @@ func (p *Parser) exprStatement() ast.Stmt {
 	}
 }
 func (p *Parser) printStatement() ast.Stmt {
+	keyword := p.previous()
 	value := p.comma()
 	if _, err := p.consume(token.SEMICOLON, "Expect ';' after value."); err != nil {
 		return nil
 	}
 	return &ast.PrintStmt{
-		Value: value,
+		Keyword: *keyword,
+		Value:   value,
+	}
+}
+func (p *Parser) returnStatement() ast.Stmt {
+	keyword := p.previous()
+	if p.funDepth == 0 {
+		errors.Error(keyword, "Can't return from top-level code.")
+	}
+	var value ast.Expr
+	if !p.check(token.SEMICOLON) {
+		value = p.comma()
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after return value."); err != nil {
+		return nil
+	}
+	return &ast.ReturnStmt{
+		Keyword: *keyword,
+		Value:   value,
+	}
+}
+func (p *Parser) assertStatement() ast.Stmt {
+	keyword := p.previous()
+	cond := p.expression()
+	var message ast.Expr
+	if p.match(token.COMMA) {
+		message = p.expression()
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after assertion."); err != nil {
+		return nil
+	}
+	return &ast.AssertStmt{
+		Keyword:   *keyword,
+		Condition: cond,
+		Message:   message,
 	}
 }
 func (p *Parser) comma() ast.Expr {
@@ -123,14 +294,51 @@ func (p *Parser) assignment() ast.Expr {
 				Value: value,
 			}
 		}
+		if exp, ok := expr.(*ast.GetNode); ok {
+			return &ast.SetNode{
+				Object: exp.Object,
+				Name:   exp.Name,
+				Value:  value,
+			}
+		}
+		if exp, ok := expr.(*ast.IndexNode); ok {
+			return &ast.SetIndexNode{
+				Object:  exp.Object,
+				Bracket: exp.Bracket,
+				Index:   exp.Index,
+				Value:   value,
+			}
+		}
 		errors.Error(equals, "Invalid assignment target.")
 		p.sync()
 	}
+	if p.match(token.PLUS_EQUAL, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL, token.PERCENT_EQUAL) {
+		op := p.previous()
+		value := p.assignment()
+		if isAssignable(expr) {
+			return &ast.CompoundAssignNode{
+				Target: expr,
+				Op:     *op,
+				Value:  value,
+			}
+		}
+		errors.Error(op, "Invalid assignment target.")
+		p.sync()
+	}
 	return expr
 }
+func isAssignable(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.VariableNode, *ast.IndexNode, *ast.GetNode:
+		return true
+	default:
+		return false
+	}
+}
 func (p *Parser) ternary() ast.Expr {
 	expr := p.equality()
 	if p.match(token.QUESTION_MARK) {
+		question := p.previous()
 		left := p.expression()
 		if !p.match(token.COLON) {
 			errors.Error(p.peek(), "Expect a ':'.")
@@ -140,6 +348,7 @@ func (p *Parser) ternary() ast.Expr {
 		right := p.expression()
 		expr = &ast.ConditionNode{
 			Condition: expr,
+			Question:  *question,
 			Truth:     left,
 			False:     right,
 		}
@@ -160,10 +369,38 @@ func (p *Parser) equality() ast.Expr {
 	return expr
 }
 func (p *Parser) comparison() ast.Expr {
-	expr := p.term()
+	expr := p.bitOr()
 	for p.match(token.LESS, token.LESS_EQUAL, token.GREATER, token.GREATER_EQUAL) {
 		op := p.previous()
-		right := p.term()
+		right := p.bitOr()
+		expr = &ast.BinaryNode{
+			Left:  expr,
+			Op:    *op,
+			Right: right,
+		}
+	}
+	return expr
+}
+
+// The bitwise operators bind tighter than comparison, as in Python, so
+// that "x & mask == 0" means "(x & mask) == 0".
+func (p *Parser) bitOr() ast.Expr {
+	return p.binary(p.bitXor, token.PIPE)
+}
+func (p *Parser) bitXor() ast.Expr {
+	return p.binary(p.bitAnd, token.CARET)
+}
+func (p *Parser) bitAnd() ast.Expr {
+	return p.binary(p.shift, token.AMPERSAND)
+}
+func (p *Parser) shift() ast.Expr {
+	return p.binary(p.term, token.LESS_LESS, token.GREATER_GREATER)
+}
+func (p *Parser) binary(operand func() ast.Expr, types ...token.TokenType) ast.Expr {
+	expr := operand()
+	for p.match(types...) {
+		op := p.previous()
+		right := operand()
 		expr = &ast.BinaryNode{
 			Left:  expr,
 			Op:    *op,
@@ -187,7 +424,7 @@ func (p *Parser) term() ast.Expr {
 }
 func (p *Parser) factor() ast.Expr {
 	expr := p.unary()
-	for p.match(token.SLASH, token.STAR) {
+	for p.match(token.SLASH, token.STAR, token.PERCENT, token.TILDE_SLASH) {
 		op := p.previous()
 		right := p.unary()
 		expr = &ast.BinaryNode{
@@ -199,7 +436,19 @@ func (p *Parser) factor() ast.Expr {
 	return expr
 }
 func (p *Parser) unary() ast.Expr {
-	if p.match(token.MINUS, token.BANG) {
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
+		op := p.previous()
+		target := p.unary()
+		if !isAssignable(target) {
+			errors.Error(op, "Invalid increment target.")
+		}
+		return &ast.UpdateNode{
+			Target: target,
+			Op:     *op,
+			Prefix: true,
+		}
+	}
+	if p.match(token.MINUS, token.BANG, token.TILDE) {
 		op := p.previous()
 		expr := p.unary()
 		return &ast.UnaryNode{
@@ -207,26 +456,214 @@ func (p *Parser) unary() ast.Expr {
 			Right: expr,
 		}
 	}
-	return p.primary()
+	return p.power()
+}
+
+// power is right-associative and binds tighter than the prefix operators,
+// so "-2 ** 2" is -4 while "2 ** -1" is still accepted.
+func (p *Parser) power() ast.Expr {
+	expr := p.postfix()
+	if p.match(token.STAR_STAR) {
+		op := p.previous()
+		right := p.unary()
+		return &ast.BinaryNode{
+			Left:  expr,
+			Op:    *op,
+			Right: right,
+		}
+	}
+	return expr
+}
+func (p *Parser) postfix() ast.Expr {
+	expr := p.call()
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
+		op := p.previous()
+		if !isAssignable(expr) {
+			errors.Error(op, "Invalid increment target.")
+		}
+		return &ast.UpdateNode{
+			Target: expr,
+			Op:     *op,
+		}
+	}
+	return expr
+}
+func (p *Parser) call() ast.Expr {
+	expr := p.primary()
+	for {
+		if p.match(token.LEFT_PAREN) {
+			expr = p.finishCall(expr)
+		} else if p.match(token.LEFT_BRACKET) {
+			bracket := p.previous()
+			index := p.expression()
+			if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+				return nil
+			}
+			expr = &ast.IndexNode{
+				Object:  expr,
+				Bracket: *bracket,
+				Index:   index,
+			}
+		} else if p.match(token.DOT) {
+			name, err := p.consume(token.IDENTIFIER, "Expect property name after '.'.")
+			if err != nil {
+				return nil
+			}
+			expr = &ast.GetNode{
+				Object: expr,
+				Name:   *name,
+			}
+		} else {
+			return expr
+		}
+	}
+}
+func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
+	args := make([]ast.Expr, 0, 4)
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(args) >= 255 {
+				errors.Error(p.peek(), "Can't have more than 255 arguments.")
+			}
+			// Arguments are parsed above the comma operator so that ','
+			// separates them.
+			args = append(args, p.expression())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	paren, err := p.consume(token.RIGHT_PAREN, "Expect ')' after arguments.")
+	if err != nil {
+		return nil
+	}
+	return &ast.CallNode{
+		Callee: callee,
+		Paren:  *paren,
+		Args:   args,
+	}
+}
+func (p *Parser) list() ast.Expr {
+	bracket := p.previous()
+	elements := make([]ast.Expr, 0, 4)
+	for !p.check(token.RIGHT_BRACKET) && !p.isAtEnd() {
+		elements = append(elements, p.expression())
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements."); err != nil {
+		return nil
+	}
+	return &ast.ListNode{
+		Bracket:  *bracket,
+		Elements: elements,
+	}
+}
+func (p *Parser) mapLiteral() ast.Expr {
+	brace := p.previous()
+	keys := make([]ast.Expr, 0, 4)
+	values := make([]ast.Expr, 0, 4)
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		keys = append(keys, p.expression())
+		if _, err := p.consume(token.COLON, "Expect ':' after map key."); err != nil {
+			return nil
+		}
+		values = append(values, p.expression())
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after map entries."); err != nil {
+		return nil
+	}
+	return &ast.MapNode{
+		Brace:  *brace,
+		Keys:   keys,
+		Values: values,
+	}
+}
+func (p *Parser) lambda() ast.Expr {
+	keyword := p.previous()
+	params, body := p.functionBody("function")
+	return &ast.LambdaNode{
+		Keyword: *keyword,
+		Params:  params,
+		Body:    body,
+	}
+}
+
+// arrow parses "(a, b) => body" once isArrow has confirmed the shape. The
+// body is either a block or a single expression whose value is returned.
+func (p *Parser) arrow() ast.Expr {
+	p.consume(token.LEFT_PAREN, "Expect '(' before parameters.")
+	params := p.parameters()
+	p.consume(token.RIGHT_PAREN, "Expect ')' after parameters.")
+	arrow, err := p.consume(token.ARROW, "Expect '=>' after parameters.")
+	if err != nil {
+		return nil
+	}
+	p.funDepth++
+	defer func() { p.funDepth-- }()
+	var body []ast.Stmt
+	if p.match(token.LEFT_BRACE) {
+		body = p.blockStatement()
+	} else {
+		body = []ast.Stmt{&ast.ReturnStmt{
+			Keyword: *arrow,
+			Value:   p.expression(),
+		}}
+	}
+	return &ast.LambdaNode{
+		Keyword: *arrow,
+		Params:  params,
+		Body:    body,
+	}
+}
+
+// isArrow looks ahead from a '(' for a parameter list followed by '=>'
+// without consuming anything.
+func (p *Parser) isArrow() bool {
+	i := p.current + 1
+	if p.tokens[i].Typ != token.RIGHT_PAREN {
+		for {
+			if p.tokens[i].Typ != token.IDENTIFIER {
+				return false
+			}
+			i++
+			if p.tokens[i].Typ != token.COMMA {
+				break
+			}
+			i++
+		}
+		if p.tokens[i].Typ != token.RIGHT_PAREN {
+			return false
+		}
+	}
+	return p.tokens[i+1].Typ == token.ARROW
 }
 func (p *Parser) primary() ast.Expr {
 	if p.match(token.FALSE) {
 		return &ast.LiteralNode{
+			Token: *p.previous(),
 			Value: false,
 		}
 	}
 	if p.match(token.TRUE) {
 		return &ast.LiteralNode{
+			Token: *p.previous(),
 			Value: true,
 		}
 	}
 	if p.match(token.NIL) {
 		return &ast.LiteralNode{
+			Token: *p.previous(),
 			Value: nil,
 		}
 	}
 	if p.match(token.NUMBER, token.STRING) {
 		return &ast.LiteralNode{
+			Token: *p.previous(),
 			Value: p.previous().Literal,
 		}
 	}
@@ -235,6 +672,19 @@ func (p *Parser) primary() ast.Expr {
 			Name: *p.previous(),
 		}
 	}
+	if p.match(token.FUN) {
+		return p.lambda()
+	}
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+	// A '{' that starts a statement is a block; here it must be a map.
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+	if p.check(token.LEFT_PAREN) && p.isArrow() {
+		return p.arrow()
+	}
 	if p.match(token.LEFT_PAREN) {
 		expr := p.comma()
 		if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after expression."); err != nil {
@@ -260,7 +710,9 @@ func (p *Parser) sync() {
 			return
 		case token.FUN:
 			return
-		case token.VAR:
+		case token.VAR, token.LET, token.CONST:
+			return
+		case token.IMPORT, token.EXPORT:
 			return
 		case token.FOR:
 			return
@@ -268,7 +720,7 @@ func (p *Parser) sync() {
 			return
 		case token.IF:
 			return
-		case token.PRINT:
+		case token.PRINT, token.ASSERT:
 			return
 		case token.RETURN:
 			return
@@ -300,6 +752,12 @@ func (p *Parser) check(typ token.TokenType) bool {
 	}
 	return p.peek().Typ == typ
 }
+func (p *Parser) checkNext(typ token.TokenType) bool {
+	if p.isAtEnd() || p.tokens[p.current+1].Typ == token.EOF {
+		return false
+	}
+	return p.tokens[p.current+1].Typ == typ
+}
 func (p *Parser) advance() *token.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -0,0 +1,127 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"lox/interpreter"
+	"maps"
+	"slices"
+)
+
+// WriteProfile writes p to w as a gzipped profile.proto, the format of
+// go tool pprof. Its samples have two values, the calls and the
+// nanoseconds spent, and its locations are lines of Lox functions.
+func (p *Profile) WriteProfile(w io.Writer) error {
+	index := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		idx, ok := index[s]
+		if !ok {
+			idx = int64(len(table))
+			index[s] = idx
+			table = append(table, s)
+		}
+		return idx
+	}
+	valueType := func(kind, unit string) func(*encoder) {
+		return func(e *encoder) {
+			e.int(1, str(kind))
+			e.int(2, str(unit))
+		}
+	}
+
+	var e encoder
+	e.message(1, valueType("calls", "count"))
+	e.message(1, valueType("time", "nanoseconds"))
+	samples := p.Samples()
+	seen := make(map[*Location]bool)
+	var locations []*Location
+	for _, s := range samples {
+		// pprof lists the innermost location first.
+		ids := make([]int64, len(s.Stack))
+		for idx, loc := range s.Stack {
+			ids[len(ids)-1-idx] = int64(loc.ID)
+			if !seen[loc] {
+				seen[loc] = true
+				locations = append(locations, loc)
+			}
+		}
+		e.message(2, func(e *encoder) {
+			e.packed(1, ids)
+			e.packed(2, []int64{int64(s.Calls), s.Time.Nanoseconds()})
+		})
+	}
+	slices.SortFunc(locations, func(a, b *Location) int { return a.ID - b.ID })
+	functions := make(map[*Function]bool)
+	for _, loc := range locations {
+		e.message(4, func(e *encoder) {
+			e.int(1, int64(loc.ID))
+			e.message(4, func(e *encoder) {
+				e.int(1, int64(loc.Function.ID))
+				e.int(2, int64(loc.Line))
+			})
+		})
+		functions[loc.Function] = true
+	}
+	for _, fn := range slices.SortedFunc(maps.Keys(functions), func(a, b *Function) int { return a.ID - b.ID }) {
+		e.message(5, func(e *encoder) {
+			e.int(1, int64(fn.ID))
+			e.int(2, str(fn.Name))
+			e.int(3, str(fn.Name))
+			e.int(4, str(interpreter.DisplayName(fn.File)))
+			e.int(5, int64(fn.Line))
+		})
+	}
+	e.int(9, p.start.UnixNano())
+	e.int(10, p.Duration().Nanoseconds())
+	e.int(14, str("time"))
+	// The string table comes last, once every string is in it.
+	for _, s := range table {
+		e.bytes(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(e.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// encoder appends protocol buffer fields to buf.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+// int appends an integer field, unless it is 0.
+func (e *encoder) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.varint(uint64(field) << 3)
+	e.varint(uint64(v))
+}
+func (e *encoder) bytes(field int, b []byte) {
+	e.varint(uint64(field)<<3 | 2)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+func (e *encoder) packed(field int, vs []int64) {
+	var sub encoder
+	for _, v := range vs {
+		sub.varint(uint64(v))
+	}
+	e.bytes(field, sub.buf)
+}
+func (e *encoder) message(field int, write func(*encoder)) {
+	var sub encoder
+	write(&sub)
+	e.bytes(field, sub.buf)
+}
@@ -0,0 +1,312 @@
+// Package profiler measures where Lox scripts spend their time. A Profile
+// charges the time between the statements, calls and returns reported by
+// an interpreter.Observer to the Lox call stack they happen in, and counts
+// the calls of each function. It writes what it measured as a pprof
+// profile, as folded stacks for flame graphs, or as a text summary.
+package profiler
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"lox/ast"
+	"lox/interpreter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Function is a Lox function, or the top level of a file.
+type Function struct {
+	ID int
+	// Name is the name of the function, "[anonymous]" for a lambda and
+	// "[script]" for the top level of a file. pprof drops names in angle
+	// brackets as C++ template arguments, hence the square ones.
+	Name string
+	// File is the key of the file that declares it and Line the line of
+	// its declaration, 0 for a top level.
+	File string
+	Line int
+}
+
+// Location is a line of a function.
+type Location struct {
+	ID       int
+	Function *Function
+	Line     int
+}
+
+// Sample holds what was measured on one call stack.
+type Sample struct {
+	// Stack holds the line each function on the stack was at, from the
+	// outermost.
+	Stack []*Location
+	// Calls counts the calls that made the stack and Time is the time
+	// spent running its innermost line.
+	Calls int
+	Time  time.Duration
+}
+
+// Profile holds the samples measured so far.
+type Profile struct {
+	// now reads the clock.
+	now         func() time.Time
+	start, last time.Time
+	stack       []frame
+	functions   map[Function]*Function
+	locations   map[Location]*Location
+	samples     map[string]*Sample
+	// key is reused to build the keys of samples.
+	key []byte
+}
+
+// frame is a call on the Lox call stack.
+type frame struct {
+	fn   *Function
+	line int
+}
+
+func NewProfile() *Profile {
+	return &Profile{
+		now:       time.Now,
+		functions: make(map[Function]*Function),
+		locations: make(map[Location]*Location),
+		samples:   make(map[string]*Sample),
+	}
+}
+
+// Observer returns an observer that measures what interp runs in p and
+// starts the clock. Stop stops it.
+func (p *Profile) Observer(interp *interpreter.Interpreter) interpreter.Observer {
+	p.start = p.now()
+	p.last = p.start
+	p.stack = p.stack[:0]
+	return &recorder{profile: p, interp: interp}
+}
+
+// Stop charges the time since the last event to the stack it happened in.
+func (p *Profile) Stop() {
+	p.charge()
+}
+
+// Duration returns the time from the start of the clock to its last
+// reading.
+func (p *Profile) Duration() time.Duration {
+	return p.last.Sub(p.start)
+}
+
+func (p *Profile) function(name, file string, line int) *Function {
+	key := Function{Name: name, File: file, Line: line}
+	fn, ok := p.functions[key]
+	if !ok {
+		fn = &Function{ID: len(p.functions) + 1, Name: name, File: file, Line: line}
+		p.functions[key] = fn
+	}
+	return fn
+}
+func (p *Profile) location(fn *Function, line int) *Location {
+	key := Location{Function: fn, Line: line}
+	loc, ok := p.locations[key]
+	if !ok {
+		loc = &Location{ID: len(p.locations) + 1, Function: fn, Line: line}
+		p.locations[key] = loc
+	}
+	return loc
+}
+
+// sample returns the sample of the current stack.
+func (p *Profile) sample() *Sample {
+	p.key = p.key[:0]
+	for _, f := range p.stack {
+		p.key = strconv.AppendInt(p.key, int64(p.location(f.fn, f.line).ID), 10)
+		p.key = append(p.key, ';')
+	}
+	if s, ok := p.samples[string(p.key)]; ok {
+		return s
+	}
+	s := &Sample{Stack: make([]*Location, 0, len(p.stack))}
+	for _, f := range p.stack {
+		s.Stack = append(s.Stack, p.location(f.fn, f.line))
+	}
+	p.samples[string(p.key)] = s
+	return s
+}
+
+// charge adds the time since the last event to the current stack.
+func (p *Profile) charge() {
+	now := p.now()
+	if d := now.Sub(p.last); d > 0 && len(p.stack) > 0 {
+		p.sample().Time += d
+	}
+	p.last = now
+}
+
+type recorder struct {
+	interpreter.NopObserver
+	profile *Profile
+	interp  *interpreter.Interpreter
+}
+
+func (r *recorder) Statement(stmt ast.Stmt, env *interpreter.Environment) error {
+	switch stmt.(type) {
+	case *ast.BlockStmt, *ast.ExportStmt:
+		// Their statements, and the declaration exported, run instead.
+		return nil
+	}
+	p := r.profile
+	p.charge()
+	if len(p.stack) == 0 {
+		p.stack = append(p.stack, frame{})
+	}
+	top := &p.stack[len(p.stack)-1]
+	if len(p.stack) == 1 {
+		// The top level runs the files it imports too.
+		top.fn = p.function("[script]", r.interp.File(), 0)
+	}
+	top.line = ast.Line(stmt)
+	return nil
+}
+func (r *recorder) Call(fn *interpreter.Function, env *interpreter.Environment) {
+	p := r.profile
+	p.charge()
+	name := fn.Name()
+	if name == "" {
+		name = "[anonymous]"
+	}
+	p.stack = append(p.stack, frame{fn: p.function(name, fn.File(), fn.Line()), line: fn.Line()})
+	p.sample().Calls++
+}
+func (r *recorder) Return(fn *interpreter.Function, value any, err error) {
+	p := r.profile
+	p.charge()
+	if len(p.stack) > 1 {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// Samples returns the samples of p, sorted by stack.
+func (p *Profile) Samples() []*Sample {
+	samples := make([]*Sample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	slices.SortFunc(samples, func(a, b *Sample) int {
+		return slices.CompareFunc(a.Stack, b.Stack, func(a, b *Location) int { return cmp.Compare(a.ID, b.ID) })
+	})
+	return samples
+}
+
+// WriteFolded writes the time of each stack of functions to w, one per
+// line as the names of the functions from the outermost, separated by
+// semicolons, and the nanoseconds spent, the format flame graph tools
+// read. Stacks with no time are left out.
+func (p *Profile) WriteFolded(w io.Writer) error {
+	stacks := make(map[string]time.Duration)
+	for _, s := range p.samples {
+		if s.Time == 0 {
+			continue
+		}
+		names := make([]string, len(s.Stack))
+		for idx, loc := range s.Stack {
+			names[idx] = loc.Function.Name
+		}
+		stacks[strings.Join(names, ";")] += s.Time
+	}
+	keys := make([]string, 0, len(stacks))
+	for key := range stacks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s %d\n", key, stacks[key].Nanoseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryRows is the number of functions and of lines WriteSummary lists.
+const summaryRows = 10
+
+// WriteSummary writes the functions and the lines that took the most time
+// to w. The self time of a function is spent on its own lines, its total
+// time in its calls too.
+func (p *Profile) WriteSummary(w io.Writer) error {
+	type row struct {
+		name        string
+		calls       int
+		self, total time.Duration
+	}
+	functions := make(map[*Function]*row)
+	lines := make(map[*Location]*row)
+	var total time.Duration
+	for _, s := range p.samples {
+		total += s.Time
+		leaf := s.Stack[len(s.Stack)-1]
+		seen := make(map[*Function]bool)
+		for _, loc := range s.Stack {
+			if seen[loc.Function] {
+				// A recursive call counts once.
+				continue
+			}
+			seen[loc.Function] = true
+			r, ok := functions[loc.Function]
+			if !ok {
+				r = &row{name: functionName(loc.Function)}
+				functions[loc.Function] = r
+			}
+			r.total += s.Time
+		}
+		r := functions[leaf.Function]
+		r.calls += s.Calls
+		r.self += s.Time
+		if s.Time > 0 {
+			l, ok := lines[leaf]
+			if !ok {
+				l = &row{name: fmt.Sprintf("%s:%d (%s)", interpreter.DisplayName(leaf.Function.File), leaf.Line, leaf.Function.Name)}
+				lines[leaf] = l
+			}
+			l.self += s.Time
+		}
+	}
+	sorted := func(rows []*row) []*row {
+		slices.SortFunc(rows, func(a, b *row) int {
+			return cmp.Or(cmp.Compare(b.self, a.self), cmp.Compare(b.total, a.total), cmp.Compare(a.name, b.name))
+		})
+		return rows[:min(len(rows), summaryRows)]
+	}
+	percent := func(d time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(d) / float64(total)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "profile: %s in %d functions\n", total.Round(time.Microsecond), len(functions))
+	fmt.Fprintf(&out, "%10s %10s %6s %10s %6s  %s\n", "calls", "self", "", "total", "", "function")
+	for _, r := range sorted(slices.Collect(maps.Values(functions))) {
+		fmt.Fprintf(&out, "%10d %10s %5.1f%% %10s %5.1f%%  %s\n", r.calls, ms(r.self), percent(r.self), ms(r.total), percent(r.total), r.name)
+	}
+	fmt.Fprintf(&out, "%10s %6s  %s\n", "self", "", "line")
+	for _, r := range sorted(slices.Collect(maps.Values(lines))) {
+		fmt.Fprintf(&out, "%10s %5.1f%%  %s\n", ms(r.self), percent(r.self), r.name)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// ms formats d in milliseconds.
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+// functionName names fn with the place it is declared.
+func functionName(fn *Function) string {
+	if fn.Line == 0 {
+		return fmt.Sprintf("%s (%s)", fn.Name, interpreter.DisplayName(fn.File))
+	}
+	return fmt.Sprintf("%s (%s:%d)", fn.Name, interpreter.DisplayName(fn.File), fn.Line)
+}
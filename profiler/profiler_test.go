@@ -0,0 +1,98 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"lox/interpreter"
+	"lox/parser"
+	"lox/scanner"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `fun f(n) {
+  return n < 1 ? 0 : f(n - 1);
+}
+var g = fun (x) { return x; };
+print g(f(1));
+`
+
+func TestProfile(t *testing.T) {
+	profile := NewProfile()
+	// Each reading of the clock is a millisecond after the last.
+	clock := time.Unix(0, 0)
+	profile.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	interp := interpreter.NewInterpreter(interpreter.NewEnvironment(nil))
+	interp.SetFile("main.lox")
+	interp.SetOutput(io.Discard)
+	interp.SetObserver(profile.Observer(interp))
+	if _, err := interp.Run(parser.NewParser(scanner.NewSacnner(program).ScanTokens()).Parse()); err != nil {
+		t.Fatal(err)
+	}
+	profile.Stop()
+
+	var out strings.Builder
+	if err := profile.WriteFolded(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[script] 5000000
+[script];[anonymous] 2000000
+[script];f 3000000
+[script];f;f 2000000
+`
+	if out.String() != expected {
+		t.Errorf("expected folded stacks\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := profile.WriteSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"profile: 12ms in 3 functions\n",
+		"         0    5.000ms  41.7%   12.000ms 100.0%  [script] (main.lox)\n",
+		"         2    5.000ms  41.7%    5.000ms  41.7%  f (main.lox:1)\n",
+		"         1    2.000ms  16.7%    2.000ms  16.7%  [anonymous] (main.lox:4)\n",
+		"   3.000ms  25.0%  main.lox:2 (f)\n",
+		"   3.000ms  25.0%  main.lox:5 ([script])\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected summary to contain %q, got\n%s", want, out.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := profile.WriteProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"calls", "count", "time", "nanoseconds", "[script]", "[anonymous]", "main.lox"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("expected the profile to contain %q", want)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var e encoder
+	e.int(1, 150)
+	e.int(2, 0)
+	e.message(3, func(e *encoder) { e.packed(4, []int64{3, 270}) })
+	e.bytes(5, []byte("ab"))
+	expected := []byte{0x08, 0x96, 0x01, 0x1a, 0x05, 0x22, 0x03, 0x03, 0x8e, 0x02, 0x2a, 0x02, 'a', 'b'}
+	if !bytes.Equal(e.buf, expected) {
+		t.Errorf("expected % x, got % x", expected, e.buf)
+	}
+}
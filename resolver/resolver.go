@@ -0,0 +1,262 @@
+package resolver
+
+import (
+	"cmp"
+	"fmt"
+	"lox/ast"
+	"lox/errors"
+	"lox/token"
+	"slices"
+)
+
+// Resolver statically walks a parsed program before it runs and reports
+// errors that do not depend on runtime values, such as assigning to a
+// const. Problems are reported through errors.Error like parse errors.
+// It also records the symbols of the program for tools such as the
+// language server.
+type Resolver struct {
+	// scopes maps each name declared in a scope to its symbol. The first
+	// scope is the top level of the file.
+	scopes []map[string]*Symbol
+	// functions holds the index in scopes of the parameters of each
+	// function or test being resolved.
+	functions []int
+	symbols   []*Symbol
+	// pending holds the uses not declared yet when they were met.
+	pending []pendingUse
+}
+
+// Symbol is a declared name and the places that refer to it.
+type Symbol struct {
+	Name token.Token
+	// Kind is the declaring keyword: VAR, LET, CONST or FUN. Parameters
+	// are VAR and imports CONST.
+	Kind  token.TokenType
+	Param bool
+	// Depth is the number of scopes around the declaration, 0 at the top
+	// level.
+	Depth int
+	// Value is the initializer of a variable and Params the parameters of
+	// a function.
+	Value  ast.Expr
+	Params []token.Token
+	// Refs are the other tokens naming the symbol, including declarations
+	// of the same var again, in source order.
+	Refs []token.Token
+}
+
+// pendingUse is a name used in a function before it was declared. The
+// function runs later, so the name is looked up again in the scopes
+// around the function once they are complete.
+type pendingUse struct {
+	name   token.Token
+	scopes []map[string]*Symbol
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{
+		scopes: []map[string]*Symbol{make(map[string]*Symbol)},
+	}
+}
+
+// Symbols returns the symbols declared in the resolved statements, in the
+// order of their declarations. Names that refer to no declaration, like
+// those of native functions, are left out.
+func (r *Resolver) Symbols() []*Symbol {
+	for _, use := range r.pending {
+		for i := len(use.scopes) - 1; i >= 0; i-- {
+			if symbol, ok := use.scopes[i][use.name.Lexeme]; ok {
+				symbol.Refs = append(symbol.Refs, use.name)
+				break
+			}
+		}
+	}
+	r.pending = nil
+	for _, symbol := range r.symbols {
+		slices.SortFunc(symbol.Refs, func(a, b token.Token) int {
+			return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+		})
+	}
+	return r.symbols
+}
+func (r *Resolver) Resolve(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		r.resolveStmt(stmt)
+	}
+}
+func (r *Resolver) resolveStmt(stmt ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStmt:
+		r.resolveExpr(stmt.Expression)
+	case *ast.PrintStmt:
+		r.resolveExpr(stmt.Value)
+	case *ast.VariableStmt:
+		if stmt.Value != nil {
+			r.resolveExpr(stmt.Value)
+		}
+		r.declare(stmt.Name, stmt.Kind).Value = stmt.Value
+	case *ast.FunctionStmt:
+		r.declare(stmt.Name, token.FUN).Params = stmt.Params
+		r.resolveFunction(stmt.Params, stmt.Body)
+	case *ast.ImportStmt:
+		if len(r.scopes) > 1 {
+			errors.Error(&stmt.Keyword, "Can only import at the top level.")
+		}
+		r.declare(stmt.Name, token.CONST)
+	case *ast.ExportStmt:
+		if len(r.scopes) > 1 {
+			errors.Error(&stmt.Keyword, "Can only export at the top level.")
+		}
+		r.resolveStmt(stmt.Decl)
+	case *ast.TestStmt:
+		if len(r.scopes) > 1 {
+			errors.Error(&stmt.Keyword, "Can only declare tests at the top level.")
+		}
+		r.resolveFunction(nil, stmt.Body)
+	case *ast.AssertStmt:
+		r.resolveExpr(stmt.Condition)
+		if stmt.Message != nil {
+			r.resolveExpr(stmt.Message)
+		}
+	case *ast.ReturnStmt:
+		if stmt.Value != nil {
+			r.resolveExpr(stmt.Value)
+		}
+	case *ast.BlockStmt:
+		r.beginScope()
+		r.Resolve(stmt.Stmts)
+		r.endScope()
+	case *ast.IfStmt:
+		r.resolveExpr(stmt.Cond)
+		r.resolveStmt(stmt.Then)
+		if stmt.Else != nil {
+			r.resolveStmt(stmt.Else)
+		}
+	}
+}
+func (r *Resolver) resolveFunction(params []token.Token, body []ast.Stmt) {
+	r.beginScope()
+	r.functions = append(r.functions, len(r.scopes)-1)
+	for _, param := range params {
+		r.declare(param, token.VAR).Param = true
+	}
+	r.Resolve(body)
+	r.functions = r.functions[:len(r.functions)-1]
+	r.endScope()
+}
+func (r *Resolver) resolveExpr(expr ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.VariableNode:
+		r.use(expr.Name)
+	case *ast.AssignNode:
+		r.resolveExpr(expr.Value)
+		r.use(expr.Name)
+		r.checkAssignable(expr.Name)
+	case *ast.CompoundAssignNode:
+		r.resolveExpr(expr.Target)
+		r.resolveExpr(expr.Value)
+		if target, ok := expr.Target.(*ast.VariableNode); ok {
+			r.checkAssignable(target.Name)
+		}
+	case *ast.UpdateNode:
+		r.resolveExpr(expr.Target)
+		if target, ok := expr.Target.(*ast.VariableNode); ok {
+			r.checkAssignable(target.Name)
+		}
+	case *ast.BinaryNode:
+		r.resolveExpr(expr.Left)
+		r.resolveExpr(expr.Right)
+	case *ast.UnaryNode:
+		r.resolveExpr(expr.Right)
+	case *ast.GroupNode:
+		r.resolveExpr(expr.Expression)
+	case *ast.ConditionNode:
+		r.resolveExpr(expr.Condition)
+		r.resolveExpr(expr.Truth)
+		r.resolveExpr(expr.False)
+	case *ast.CallNode:
+		r.resolveExpr(expr.Callee)
+		for _, arg := range expr.Args {
+			r.resolveExpr(arg)
+		}
+	case *ast.LambdaNode:
+		r.resolveFunction(expr.Params, expr.Body)
+	case *ast.GetNode:
+		r.resolveExpr(expr.Object)
+	case *ast.ListNode:
+		for _, element := range expr.Elements {
+			r.resolveExpr(element)
+		}
+	case *ast.MapNode:
+		for idx := range expr.Keys {
+			r.resolveExpr(expr.Keys[idx])
+			r.resolveExpr(expr.Values[idx])
+		}
+	case *ast.SetNode:
+		r.resolveExpr(expr.Object)
+		r.resolveExpr(expr.Value)
+	case *ast.IndexNode:
+		r.resolveExpr(expr.Object)
+		r.resolveExpr(expr.Index)
+	case *ast.SetIndexNode:
+		r.resolveExpr(expr.Object)
+		r.resolveExpr(expr.Index)
+		r.resolveExpr(expr.Value)
+	}
+}
+
+// declare adds name to the innermost scope and returns its symbol. var
+// and fun may be declared again, as Lox has always allowed, which adds a
+// reference to the same symbol, but let and const may not clash with
+// anything else in the same scope.
+func (r *Resolver) declare(name token.Token, kind token.TokenType) *Symbol {
+	scope := r.scopes[len(r.scopes)-1]
+	if prev, ok := scope[name.Lexeme]; ok {
+		if !isStrict(prev.Kind) && !isStrict(kind) {
+			prev.Refs = append(prev.Refs, name)
+			return prev
+		}
+		errors.Error(&name, "Already a variable with this name in this scope.")
+	}
+	symbol := &Symbol{Name: name, Kind: kind, Depth: len(r.scopes) - 1}
+	scope[name.Lexeme] = symbol
+	r.symbols = append(r.symbols, symbol)
+	return symbol
+}
+
+// use records name as a reference to the symbol it names. A name not
+// declared yet is looked up again later if it is in a function or test,
+// and among the globals otherwise.
+func (r *Resolver) use(name token.Token) {
+	if symbol := r.lookup(name); symbol != nil {
+		symbol.Refs = append(symbol.Refs, name)
+		return
+	}
+	outer := 1
+	if len(r.functions) > 0 {
+		outer = r.functions[len(r.functions)-1]
+	}
+	r.pending = append(r.pending, pendingUse{name: name, scopes: slices.Clone(r.scopes[:outer])})
+}
+func (r *Resolver) lookup(name token.Token) *Symbol {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if symbol, ok := r.scopes[i][name.Lexeme]; ok {
+			return symbol
+		}
+	}
+	return nil
+}
+func (r *Resolver) checkAssignable(name token.Token) {
+	if symbol := r.lookup(name); symbol != nil && symbol.Kind == token.CONST {
+		errors.Error(&name, fmt.Sprintf("Cannot assign to constant '%s'.", name.Lexeme))
+	}
+}
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*Symbol))
+}
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+func isStrict(kind token.TokenType) bool {
+	return kind == token.LET || kind == token.CONST
+}
@@ -0,0 +1,103 @@
+package resolver
+
+import (
+	"lox/errors"
+	"lox/parser"
+	"lox/scanner"
+	"slices"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	tests := []struct {
+		source string
+		errors int
+	}{
+		{
+			source: `const a = 1; print a;`,
+			errors: 0,
+		},
+		{
+			source: `const a = 1; a = 2;`,
+			errors: 1,
+		},
+		{
+			source: `const a = 1; a += 2; a++;`,
+			errors: 2,
+		},
+		{
+			source: `const a = 1; { var a = 2; a = 3; }`,
+			errors: 0,
+		},
+		{
+			source: `const a = 1; fun f() { a = 2; }`,
+			errors: 1,
+		},
+		{
+			source: `const a = 1; test "t" { let a = 2; assert a == 2; }`,
+			errors: 0,
+		},
+		{
+			source: `fun f() { test "t" {} }`,
+			errors: 1,
+		},
+		{
+			source: `let a = 1; let a = 2;`,
+			errors: 1,
+		},
+		{
+			source: `var a = 1; var a = 2;`,
+			errors: 0,
+		},
+	}
+
+	for _, test := range tests {
+		errors.Errors = errors.Errors[:0]
+		stmts := parser.NewParser(scanner.NewSacnner(test.source).ScanTokens()).Parse()
+		NewResolver().Resolve(stmts)
+		if len(errors.Errors) != test.errors {
+			t.Errorf("%s: expected %d errors, got %v", test.source, test.errors, errors.Errors)
+		}
+	}
+	errors.Errors = errors.Errors[:0]
+}
+
+func TestSymbols(t *testing.T) {
+	source := `var a = 1;
+fun f(a) {
+  { var a = a + 1; print a; }
+  return a + b;
+}
+var b = a;
+var a = 2;
+test "t" { assert f(b) == a; }`
+	// Each symbol is listed with the lines of its declaration and
+	// references.
+	expected := []struct {
+		name  string
+		param bool
+		lines []int
+	}{
+		{"a", false, []int{1, 6, 7, 8}},
+		{"f", false, []int{2, 8}},
+		{"a", true, []int{2, 3, 4}},
+		{"a", false, []int{3, 3}},
+		{"b", false, []int{6, 4, 8}},
+	}
+	errors.Errors = errors.Errors[:0]
+	r := NewResolver()
+	r.Resolve(parser.NewParser(scanner.NewSacnner(source).ScanTokens()).Parse())
+	symbols := r.Symbols()
+	if len(errors.Errors) > 0 || len(symbols) != len(expected) {
+		t.Fatalf("expected %d symbols, got %d (%v)", len(expected), len(symbols), errors.Errors)
+	}
+	for i, symbol := range symbols {
+		lines := []int{symbol.Name.Line}
+		for _, ref := range symbol.Refs {
+			lines = append(lines, ref.Line)
+		}
+		if symbol.Name.Lexeme != expected[i].name || symbol.Param != expected[i].param || !slices.Equal(lines, expected[i].lines) {
+			t.Errorf("expected %s on lines %v, got %s on lines %v", expected[i].name, expected[i].lines, symbol.Name.Lexeme, lines)
+		}
+	}
+}
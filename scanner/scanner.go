@@ -13,22 +13,39 @@ type Scanner struct {
 	start   int
 	line    int
 	current int
+	// lineStart is the offset of the current line, and column the column
+	// of the token being scanned.
+	lineStart int
+	column    int
+	// comments makes comments appear as COMMENT tokens.
+	comments bool
 }
 
 func NewSacnner(source string) *Scanner {
 	return &Scanner{
 		source: source,
 		tokens: make([]*token.Token, 0, 10),
+		line:   1,
 	}
 }
 
+// KeepComments makes ScanTokens return comments as COMMENT tokens for
+// tools that work on the source text, such as the formatter. The parser
+// does not accept them.
+func (s *Scanner) KeepComments() *Scanner {
+	s.comments = true
+	return s
+}
 func (s *Scanner) ScanTokens() []*token.Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.column = s.start - s.lineStart
 		s.scanToken()
 
 	}
-	s.tokens = append(s.tokens, token.NewToken(token.EOF, "", nil, s.line))
+	s.start = s.current
+	s.column = s.start - s.lineStart
+	s.addToken(token.EOF)
 	return s.tokens
 }
 func (s *Scanner) scanToken() {
@@ -42,39 +59,75 @@ func (s *Scanner) scanToken() {
 		s.addToken(token.LEFT_BRACE)
 	case '}':
 		s.addToken(token.RIGHT_BRACE)
+	case '[':
+		s.addToken(token.LEFT_BRACKET)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET)
 	case ',':
 		s.addToken(token.COMMA)
 	case '.':
 		s.addToken(token.DOT)
 	case '-':
-		s.addToken(token.MINUS)
+		if s.match('-') {
+			s.addToken(token.MINUS_MINUS)
+		} else {
+			s.addToken(util.When(s.match('='), token.MINUS_EQUAL, token.MINUS))
+		}
 	case '+':
-		s.addToken(token.PLUS)
+		if s.match('+') {
+			s.addToken(token.PLUS_PLUS)
+		} else {
+			s.addToken(util.When(s.match('='), token.PLUS_EQUAL, token.PLUS))
+		}
 	case ';':
 		s.addToken(token.SEMICOLON)
 	case '*':
-		s.addToken(token.STAR)
+		if s.match('*') {
+			s.addToken(token.STAR_STAR)
+		} else {
+			s.addToken(util.When(s.match('='), token.STAR_EQUAL, token.STAR))
+		}
+	case '%':
+		s.addToken(util.When(s.match('='), token.PERCENT_EQUAL, token.PERCENT))
+	case '&':
+		s.addToken(token.AMPERSAND)
+	case '|':
+		s.addToken(token.PIPE)
+	case '^':
+		s.addToken(token.CARET)
+	case '~':
+		// Integer division is spelled '~/' because '//' starts a comment.
+		s.addToken(util.When(s.match('/'), token.TILDE_SLASH, token.TILDE))
 	case '!':
 		s.addToken(util.When(s.match('='), token.BANG_EQUAL, token.BANG))
 	case '=':
-		s.addToken(util.When(s.match('='), token.EQUAL_EQUAL, token.EQUAL))
+		if s.match('>') {
+			s.addToken(token.ARROW)
+		} else {
+			s.addToken(util.When(s.match('='), token.EQUAL_EQUAL, token.EQUAL))
+		}
 	case '<':
-		s.addToken(util.When(s.match('='), token.LESS_EQUAL, token.LESS))
+		if s.match('<') {
+			s.addToken(token.LESS_LESS)
+		} else {
+			s.addToken(util.When(s.match('='), token.LESS_EQUAL, token.LESS))
+		}
 	case '>':
-		s.addToken(util.When(s.match('='), token.GREATER_EQUAL, token.GREATER))
+		if s.match('>') {
+			s.addToken(token.GREATER_GREATER)
+		} else {
+			s.addToken(util.When(s.match('='), token.GREATER_EQUAL, token.GREATER))
+		}
 	case '/':
 		if s.match('/') {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.addComment()
 		} else if s.match('*') {
-			for s.peek() != '*' && s.peekNext() != '/' && !s.isAtEnd() {
-				s.advance()
-			}
-			s.advance()
-			s.advance()
+			s.blockComment()
 		} else {
-			s.addToken(token.SLASH)
+			s.addToken(util.When(s.match('='), token.SLASH_EQUAL, token.SLASH))
 		}
 
 	case ' ':
@@ -82,7 +135,7 @@ func (s *Scanner) scanToken() {
 	case '\t':
 
 	case '\n':
-		s.line++
+		s.newline()
 	case '"':
 		s.string()
 	case ':':
@@ -95,10 +148,24 @@ func (s *Scanner) scanToken() {
 		} else if s.isAlpha(ch) {
 			s.identifier()
 		} else {
-			errors.Report(s.line, string(ch), "Unexpected character.")
+			errors.Report(s.line, "", "Unexpected character.")
 		}
 	}
 }
+func (s *Scanner) blockComment() {
+	for !s.isAtEnd() && !(s.peek() == '*' && s.peekNext() == '/') {
+		if s.advance() == '\n' {
+			s.newline()
+		}
+	}
+	if s.isAtEnd() {
+		errors.Report(s.line, "", "Unterminated block comment.")
+		return
+	}
+	s.advance()
+	s.advance()
+	s.addComment()
+}
 func (s *Scanner) identifier() {
 	for s.isAlphaNumeric(s.peek()) {
 		s.advance()
@@ -125,10 +192,9 @@ func (s *Scanner) number() {
 }
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		if s.advance() == '\n' {
+			s.newline()
 		}
-		s.advance()
 	}
 	if s.isAtEnd() {
 		errors.Report(s.line, "", "Unterminated string.")
@@ -181,10 +247,22 @@ func (s *Scanner) advance() byte {
 	return b
 }
 
+// newline counts the line break just consumed.
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+func (s *Scanner) addComment() {
+	if s.comments {
+		s.addToken(token.COMMENT)
+	}
+}
 func (s *Scanner) addToken(typ token.TokenType) {
 	s.addTokenLiteral(typ, nil)
 }
 func (s *Scanner) addTokenLiteral(typ token.TokenType, literal any) {
 	text := string(s.source[s.start:s.current])
-	s.tokens = append(s.tokens, token.NewToken(typ, text, literal, s.line))
+	tok := token.NewToken(typ, text, literal, s.line)
+	tok.Column = s.column
+	s.tokens = append(s.tokens, tok)
 }
@@ -170,6 +170,26 @@ func TestScanner(t *testing.T) {
 			source:   `makeBreakfast(bacon, eggs, toast);`,
 			expected: []token.TokenType{token.IDENTIFIER, token.LEFT_PAREN, token.IDENTIFIER, token.COMMA, token.IDENTIFIER, token.COMMA, token.IDENTIFIER, token.RIGHT_PAREN, token.SEMICOLON, token.EOF},
 		},
+		{
+			source:   `var add = (a, b) => a + b;`,
+			expected: []token.TokenType{token.VAR, token.IDENTIFIER, token.EQUAL, token.LEFT_PAREN, token.IDENTIFIER, token.COMMA, token.IDENTIFIER, token.RIGHT_PAREN, token.ARROW, token.IDENTIFIER, token.PLUS, token.IDENTIFIER, token.SEMICOLON, token.EOF},
+		},
+		{
+			source:   `a % b ** c ~/ d;`,
+			expected: []token.TokenType{token.IDENTIFIER, token.PERCENT, token.IDENTIFIER, token.STAR_STAR, token.IDENTIFIER, token.TILDE_SLASH, token.IDENTIFIER, token.SEMICOLON, token.EOF},
+		},
+		{
+			source:   `~a & b | c ^ d << 1 >> 2; // not division`,
+			expected: []token.TokenType{token.TILDE, token.IDENTIFIER, token.AMPERSAND, token.IDENTIFIER, token.PIPE, token.IDENTIFIER, token.CARET, token.IDENTIFIER, token.LESS_LESS, token.NUMBER, token.GREATER_GREATER, token.NUMBER, token.SEMICOLON, token.EOF},
+		},
+		{
+			source:   `a += 1; b -= 2; c *= 3; d /= 4; e %= 5; f++; --g;`,
+			expected: []token.TokenType{token.IDENTIFIER, token.PLUS_EQUAL, token.NUMBER, token.SEMICOLON, token.IDENTIFIER, token.MINUS_EQUAL, token.NUMBER, token.SEMICOLON, token.IDENTIFIER, token.STAR_EQUAL, token.NUMBER, token.SEMICOLON, token.IDENTIFIER, token.SLASH_EQUAL, token.NUMBER, token.SEMICOLON, token.IDENTIFIER, token.PERCENT_EQUAL, token.NUMBER, token.SEMICOLON, token.IDENTIFIER, token.PLUS_PLUS, token.SEMICOLON, token.MINUS_MINUS, token.IDENTIFIER, token.SEMICOLON, token.EOF},
+		},
+		{
+			source:   `var xs = [1, 2]; xs[0] = xs[1];`,
+			expected: []token.TokenType{token.VAR, token.IDENTIFIER, token.EQUAL, token.LEFT_BRACKET, token.NUMBER, token.COMMA, token.NUMBER, token.RIGHT_BRACKET, token.SEMICOLON, token.IDENTIFIER, token.LEFT_BRACKET, token.NUMBER, token.RIGHT_BRACKET, token.EQUAL, token.IDENTIFIER, token.LEFT_BRACKET, token.NUMBER, token.RIGHT_BRACKET, token.SEMICOLON, token.EOF},
+		},
 	}
 
 	for _, test := range tests {
@@ -187,3 +207,16 @@ func TestScanner(t *testing.T) {
 		}
 	}
 }
+
+func TestColumns(t *testing.T) {
+	tokens := NewSacnner("var a = \"x\ny\";\n  /* c\n */ print a;").ScanTokens()
+	expected := []struct{ line, column int }{{1, 0}, {1, 4}, {1, 6}, {2, 8}, {2, 2}, {4, 4}, {4, 10}, {4, 11}, {4, 12}}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Line != expected[i].line || tok.Column != expected[i].column {
+			t.Errorf("%s: expected line %d column %d, got line %d column %d", tok.Lexeme, expected[i].line, expected[i].column, tok.Line, tok.Column)
+		}
+	}
+}
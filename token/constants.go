@@ -7,6 +7,8 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
 	DOT
 	MINUS
@@ -14,6 +16,11 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
+	AMPERSAND
+	PIPE
+	CARET
+	TILDE
 
 	BANG
 	BANG_EQUAL
@@ -23,21 +30,40 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	STAR_STAR
+	TILDE_SLASH
+	LESS_LESS
+	GREATER_GREATER
 
 	COLON
 	QUESTION_MARK
+	ARROW
+
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PERCENT_EQUAL
+	PLUS_PLUS
+	MINUS_MINUS
 
 	IDENTIFIER
 	STRING
 	NUMBER
+	COMMENT
 
 	AND
+	ASSERT
 	CLASS
+	CONST
 	ELSE
+	EXPORT
 	FALSE
 	FUN
 	FOR
 	IF
+	IMPORT
+	LET
 	NIL
 	OR
 	PRINT
@@ -53,12 +79,17 @@ const (
 
 var KeyWords = map[string]TokenType{
 	"and":    AND,
+	"assert": ASSERT,
 	"class":  CLASS,
+	"const":  CONST,
 	"else":   ELSE,
+	"export": EXPORT,
 	"false":  FALSE,
 	"for":    FOR,
 	"fun":    FUN,
 	"if":     IF,
+	"import": IMPORT,
+	"let":    LET,
 	"nil":    NIL,
 	"or":     OR,
 	"print":  PRINT,
@@ -80,6 +111,10 @@ func (t TokenType) String() string {
 		return "LEFT_BRACE"
 	case RIGHT_BRACE:
 		return "RIGHT_BRACE"
+	case LEFT_BRACKET:
+		return "LEFT_BRACKET"
+	case RIGHT_BRACKET:
+		return "RIGHT_BRACKET"
 	case COMMA:
 		return "COMMA"
 	case DOT:
@@ -94,6 +129,16 @@ func (t TokenType) String() string {
 		return "SLASH"
 	case STAR:
 		return "STAR"
+	case PERCENT:
+		return "PERCENT"
+	case AMPERSAND:
+		return "AMPERSAND"
+	case PIPE:
+		return "PIPE"
+	case CARET:
+		return "CARET"
+	case TILDE:
+		return "TILDE"
 	case BANG:
 		return "BANG"
 	case BANG_EQUAL:
@@ -110,18 +155,34 @@ func (t TokenType) String() string {
 		return "LESS"
 	case LESS_EQUAL:
 		return "LESS_EQUAL"
+	case STAR_STAR:
+		return "STAR_STAR"
+	case TILDE_SLASH:
+		return "TILDE_SLASH"
+	case LESS_LESS:
+		return "LESS_LESS"
+	case GREATER_GREATER:
+		return "GREATER_GREATER"
 	case IDENTIFIER:
 		return "IDENTIFIER"
 	case STRING:
 		return "STRING"
 	case NUMBER:
 		return "NUMBER"
+	case COMMENT:
+		return "COMMENT"
 	case AND:
 		return "AND"
+	case ASSERT:
+		return "ASSERT"
 	case CLASS:
 		return "CLASS"
+	case CONST:
+		return "CONST"
 	case ELSE:
 		return "ELSE"
+	case EXPORT:
+		return "EXPORT"
 	case FALSE:
 		return "FALSE"
 	case FUN:
@@ -130,6 +191,10 @@ func (t TokenType) String() string {
 		return "FOR"
 	case IF:
 		return "IF"
+	case IMPORT:
+		return "IMPORT"
+	case LET:
+		return "LET"
 	case NIL:
 		return "NIL"
 	case OR:
@@ -154,6 +219,22 @@ func (t TokenType) String() string {
 		return "COLON"
 	case QUESTION_MARK:
 		return "QUESTION_MARK"
+	case ARROW:
+		return "ARROW"
+	case PLUS_EQUAL:
+		return "PLUS_EQUAL"
+	case MINUS_EQUAL:
+		return "MINUS_EQUAL"
+	case STAR_EQUAL:
+		return "STAR_EQUAL"
+	case SLASH_EQUAL:
+		return "SLASH_EQUAL"
+	case PERCENT_EQUAL:
+		return "PERCENT_EQUAL"
+	case PLUS_PLUS:
+		return "PLUS_PLUS"
+	case MINUS_MINUS:
+		return "MINUS_MINUS"
 	default:
 		return "UNKNOWN"
 	}
@@ -7,6 +7,10 @@ type Token struct {
 	Lexeme  string
 	Literal any
 	Line    int
+	// Column is the byte offset of the token's first character in the
+	// line where it starts, from 0. Line is where the token ends, which
+	// is later for multi-line strings and comments.
+	Column int
 }
 
 func NewToken(typ TokenType, lexeme string, literal any, line int) *Token {
@@ -0,0 +1,32 @@
+package wire
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	var out strings.Builder
+	for _, msg := range []any{map[string]any{"seq": 1}, "é"} {
+		if err := WriteMessage(&out, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := "Content-Length: 9\r\n\r\n{\"seq\":1}Content-Length: 4\r\n\r\n\"é\""; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+	r := bufio.NewReader(strings.NewReader(out.String() + "Content-Length: x\r\n\r\n"))
+	for _, expected := range []string{`{"seq":1}`, `"é"`} {
+		body, err := ReadMessage(r)
+		if err != nil || string(body) != expected {
+			t.Errorf("expected %s, got %s (%v)", expected, body, err)
+		}
+	}
+	if _, err := ReadMessage(r); err == nil || !strings.Contains(err.Error(), "invalid Content-Length") {
+		t.Errorf("expected an invalid length error, got %v", err)
+	}
+	if _, err := ReadMessage(r); err == nil {
+		t.Error("expected an error at the end of input")
+	}
+}